@@ -7,7 +7,7 @@ import (
 )
 
 const (
-	defaultCleanupInterval = 1 // every 3 second
+	defaultCleanupInterval = 3 * time.Second
 )
 
 // Cache struct for local cache
@@ -19,6 +19,7 @@ type Cache struct {
 type cache interface {
 	Get(k string) (interface{}, bool)
 	Set(k string, x interface{}, d time.Duration)
+	Add(k string, x interface{}, d time.Duration) error
 	Delete(k string)
 	Flush()
 }
@@ -53,6 +54,12 @@ func (m *Cache) SetTTL(key string, value interface{}, seconds int) {
 	m.cache.Set(key, value, time.Duration(seconds)*time.Second)
 }
 
+// SetNXTTL set key only if it does not exist, return true if it's set.
+// Error is always nil, it's returned to be interchangeable with redis.
+func (m *Cache) SetNXTTL(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return m.cache.Add(key, value, ttl) == nil, nil
+}
+
 func (m *Cache) Del(keys ...string) {
 	for _, key := range keys {
 		m.cache.Delete(key)
@@ -1,6 +1,8 @@
 package local
 
 import (
+	"time"
+
 	_mock "github.com/stretchr/testify/mock"
 )
 
@@ -21,6 +23,11 @@ func (m *Mock) SetTTL(key string, value interface{}, seconds int) {
 	m.Called(key, value, seconds)
 }
 
+func (m *Mock) SetNXTTL(key string, value interface{}, ttl time.Duration) (bool, error) {
+	args := m.Called(key, value, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *Mock) Del(keys ...string) {
 	m.Called(keys)
 }
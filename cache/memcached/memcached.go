@@ -11,6 +11,8 @@ var (
 	ErrSetServers = errors.New("memcached: failed connecting to servers")
 	ErrInitFailed = errors.New("memcached: failed initiating memcached")
 	ErrEmptyValue = errors.New("memcached: values cannot be empty")
+	// ErrCacheMiss returned by Get if key does not exist.
+	ErrCacheMiss = _memcached.ErrCacheMiss
 )
 
 type Memcached struct {
@@ -16,8 +16,26 @@ import (
 var (
 	// ErrEmptyConnection empty client returned
 	ErrEmptyConnection = errors.New("redis: return empty connection")
+	// ErrUnexpectedReply reply of script is not as expected
+	ErrUnexpectedReply = errors.New("redis: unexpected reply")
 	intTrue            = int64(1)
 	intFalse           = int64(0)
+
+	// incrementWithin increment counter unless it reached ARGV[1], set its ttl to ARGV[2] milliseconds when created
+	// or if it has none, return 1 if incremented else 0, and remaining ttl in milliseconds.
+	incrementWithin = `local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+local c = tonumber(redis.call('GET', KEYS[1]) or '0')
+if c >= tonumber(ARGV[1]) then return {0, ttl} end
+redis.call('INCR', KEYS[1])
+if ttl == -2 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+return {1, ttl}`
 )
 
 type Redis struct {
@@ -76,7 +94,7 @@ func (r *Redis) Keys(pattern string) []string {
 }
 
 func (r *Redis) Expire(key string, seconds int64) error {
-	return r.client.Expire(r.pre(key), time.Duration(seconds)).Err()
+	return r.client.Expire(r.pre(key), time.Duration(seconds)*time.Second).Err()
 }
 
 func (r *Redis) Persist(key string) error {
@@ -102,7 +120,7 @@ func (r *Redis) Set(key string, value interface{}) error {
 }
 
 func (r *Redis) Setex(key string, value interface{}, seconds int64) error {
-	return r.client.Set(r.pre(key), value, time.Duration(seconds)).Err()
+	return r.client.Set(r.pre(key), value, time.Duration(seconds)*time.Second).Err()
 }
 
 func (r *Redis) SetNX(key string, value interface{}) error {
@@ -110,7 +128,12 @@ func (r *Redis) SetNX(key string, value interface{}) error {
 }
 
 func (r *Redis) SetNXex(key string, value interface{}, seconds int64) error {
-	return r.client.SetNX(r.pre(key), value, time.Duration(seconds)).Err()
+	return r.client.SetNX(r.pre(key), value, time.Duration(seconds)*time.Second).Err()
+}
+
+// SetNXTTL set key only if it does not exist, return true if it's set.
+func (r *Redis) SetNXTTL(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(r.pre(key), value, ttl).Result()
 }
 
 func (r *Redis) Increment(key string) error {
@@ -121,6 +144,25 @@ func (r *Redis) Decrement(key string) error {
 	return r.client.Decr(r.pre(key)).Err()
 }
 
+// IncrementWithin increment counter of fixed window atomically unless it reached limit, window starts when it's created.
+// Return whether it's incremented and remaining ttl of window, so rejected attempts don't count.
+func (r *Redis) IncrementWithin(key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	res, err := r.client.Eval(incrementWithin, []string{r.pre(key)}, limit, window.Milliseconds()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return false, 0, ErrUnexpectedReply
+	}
+	incremented, ok1 := vals[0].(int64)
+	pttl, ok2 := vals[1].(int64)
+	if !ok1 || !ok2 {
+		return false, 0, ErrUnexpectedReply
+	}
+	return incremented == 1, time.Duration(pttl) * time.Millisecond, nil
+}
+
 //--- Hashes
 
 func (r *Redis) HGet(key, field string) string {
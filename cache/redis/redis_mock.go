@@ -1,6 +1,8 @@
 package redis
 
 import (
+	"time"
+
 	_mock "github.com/stretchr/testify/mock"
 )
 
@@ -60,6 +62,11 @@ func (r *Mock) SetNXex(key string, value interface{}, seconds int64) error {
 	return r.Called(key, value, seconds).Error(0)
 }
 
+func (r *Mock) SetNXTTL(key string, value interface{}, ttl time.Duration) (bool, error) {
+	args := r.Called(key, value, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
 func (r *Mock) Increment(key string) error {
 	return r.Called(key).Error(0)
 }
@@ -68,6 +75,11 @@ func (r *Mock) Decrement(key string) error {
 	return r.Called(key).Error(0)
 }
 
+func (r *Mock) IncrementWithin(key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	args := r.Called(key, limit, window)
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
 //--- Hashes
 
 func (r *Mock) HGet(key, field string) string {
@@ -0,0 +1,243 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReloadInterval = time.Minute
+)
+
+var (
+	ErrNoCertificate = errors.New("certs: no certificate found in PEM")
+	ErrNoServerName  = errors.New("certs: server name is required to verify server certificate")
+)
+
+// Opts PEM files of certificate, its key and CAs. Files are reloaded once they change on disk.
+type Opts struct {
+	// CertFile and KeyFile optional, certificate chain and its private key.
+	CertFile string
+	KeyFile  string
+
+	// CAFiles optional, CAs to verify peer certificate with.
+	CAFiles []string
+
+	// ReloadInterval how often files are checked for changes. Default is 1 minute, negative disables reload.
+	ReloadInterval time.Duration
+}
+
+// Reloader keep certificate and CAs of Opts up to date, e.g. certificates rotated by cert-manager.
+// Handshakes use the latest certificate, failed reload keeps the previous one.
+type Reloader struct {
+	opts   Opts
+	logger *log.Logger
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes map[string]time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReloader load files of opts and watch them for changes until Close is called.
+func NewReloader(opts *Opts) (*Reloader, error) {
+	r := &Reloader{
+		opts:   *opts,
+		logger: log.New(os.Stderr, "", 0),
+		done:   make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	interval := r.opts.ReloadInterval
+	if interval == 0 {
+		interval = defaultReloadInterval
+	}
+	if interval > 0 && len(r.files()) > 0 {
+		go r.watch(interval)
+	}
+	return r, nil
+}
+
+// Reload load files regardless of their modification time.
+func (r *Reloader) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.opts.CertFile != "" || r.opts.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if len(r.opts.CAFiles) > 0 {
+		pool = x509.NewCertPool()
+		for _, file := range r.opts.CAFiles {
+			pem, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return ErrNoCertificate
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.pool, r.modTimes = cert, pool, modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// Certificate return the latest certificate, nil if there is no CertFile.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// CAs return the latest CAs, nil if there is no CAFiles.
+func (r *Reloader) CAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// Close stop watching files.
+func (r *Reloader) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// ServerConfig return server config presenting the latest certificate.
+// If there are CAFiles then client certificate is verified with them, and required unless clientAuth says otherwise.
+// Handshakes then use copy of the returned config, so changes must be made to it, e.g. NextProtos, not to its copies.
+func (r *Reloader) ServerConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		},
+	}
+	if len(r.opts.CAFiles) > 0 {
+		if clientAuth == tls.NoClientCert {
+			clientAuth = tls.RequireAndVerifyClientCert
+		}
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := config.Clone()
+			c.GetConfigForClient = nil
+			c.ClientAuth = clientAuth
+			c.ClientCAs = r.CAs()
+			return c, nil
+		}
+	}
+	return config
+}
+
+// ClientConfig return client config presenting the latest certificate if there is CertFile.
+// Server certificate is verified with the latest CAs, or system CAs if there is no CAFiles.
+// serverName is host name, or IP matched against IP SANs, verified in server certificate. It's required with CAFiles,
+// otherwise handshakes fail with ErrNoServerName, without CAFiles it's optional and defaults to the dialed host.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if r.opts.CertFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		}
+	}
+	if len(r.opts.CAFiles) > 0 {
+		// verified manually so handshakes use the latest CAs rather than the ones at creation.
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			return r.verify(cs, serverName)
+		}
+	}
+	return config
+}
+
+func (r *Reloader) verify(cs tls.ConnectionState, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("certs: server presented no certificate")
+	}
+	// not falling back to cs.ServerName, SNI is empty when connecting by IP which would skip name verification.
+	if serverName == "" {
+		return ErrNoServerName
+	}
+	opts := x509.VerifyOptions{
+		Roots:         r.CAs(),
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	if r.opts.CertFile != "" {
+		files = append(files, r.opts.CertFile)
+	}
+	if r.opts.KeyFile != "" {
+		files = append(files, r.opts.KeyFile)
+	}
+	return append(files, r.opts.CAFiles...)
+}
+
+// watch reload files once any of them is modified.
+func (r *Reloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !r.modified() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				r.logger.Printf("%s | certs | RELOAD | %v\n", time.Now().Format(time.RFC3339), err)
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *Reloader) modified() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
@@ -12,7 +12,11 @@ import (
 func main() {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
-	server := _server.Init()
+	server, err := _server.Init()
+	if err != nil {
+		log.Println("Error Init: ", err)
+		os.Exit(1)
+	}
 	go server.Start()
 	select {
 	case <-sig:
@@ -11,21 +11,24 @@ type server struct {
 	srv *_grpc.Server
 }
 
-func Init() *server {
+func Init() (*server, error) {
 	port := 60001
 	svc := &service{}
-	srv := _grpc.New(&_grpc.Opts{
+	srv, err := _grpc.New(&_grpc.Opts{
 		Port: uint16(port),
 	})
+	if err != nil {
+		return nil, err
+	}
 	_service.RegisterServiceServer(srv.Server(), svc)
 	return &server{
 		srv: srv,
-	}
+	}, nil
 }
 
 // Start start the server, blocking.
 func (s *server) Start() {
-	s.srv.Run()
+	s.srv.Run(context.Background())
 }
 
 func (s *server) Stop() {
@@ -18,6 +18,8 @@ func main() {
 		MaxRetry:        5,
 		EnableLogger:    true,
 	})
+	// instant functions use c as well.
+	_client.SetDefault(c)
 
 	fmt.Println("Get------------------------------------")
 	Get(c)
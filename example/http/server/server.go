@@ -104,13 +104,30 @@ func (s *Service) SetJSON() http.HandlerFunc {
 func (s *Service) SetMulti() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println(r.Header)
-		// stores body max up to 52MB in memory
-		err := r.ParseMultipartForm(50 << 20)
+		// files bigger than 1MB are written to temp dir, removed once handler returned.
+		u, err := _http.ParseUpload(r, &_http.UploadOpts{
+			MaxFileSize:  50 << 20,
+			MaxTotalSize: 100 << 20,
+			MaxMemory:    1 << 20,
+			AllowedTypes: []string{"image/*", "application/pdf"},
+		})
 		if err != nil {
-			fmt.Fprint(w, "error: "+err.Error())
+			_http.ResponseString(w, r, http.StatusBadRequest, "error: "+err.Error())
 			return
 		}
-		// f := r.MultipartForm
-		// ff := r.FormFile
+		files := make([]map[string]interface{}, 0, len(u.Files))
+		for _, f := range u.Files {
+			files = append(files, map[string]interface{}{
+				"field":        f.FieldName,
+				"name":         f.FileName,
+				"content_type": f.ContentType,
+				"size":         f.Size,
+				"sha256":       f.SHA256,
+			})
+		}
+		_http.ResponseJSON(w, r, http.StatusOK, map[string]interface{}{
+			"fields": u.Fields,
+			"files":  files,
+		})
 	}
 }
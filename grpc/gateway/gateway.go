@@ -0,0 +1,476 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	_protov1 "github.com/golang/protobuf/proto"
+	_grpcserver "github.com/mfathirirhas/godevkit/grpc/server"
+	_httpserver "github.com/mfathirirhas/godevkit/http/server"
+	_trace "github.com/mfathirirhas/godevkit/trace"
+	_annotations "google.golang.org/genproto/googleapis/api/annotations"
+	_grpc "google.golang.org/grpc"
+	_codes "google.golang.org/grpc/codes"
+	_metadata "google.golang.org/grpc/metadata"
+	_status "google.golang.org/grpc/status"
+	_bufconn "google.golang.org/grpc/test/bufconn"
+	_protojson "google.golang.org/protobuf/encoding/protojson"
+	_proto "google.golang.org/protobuf/proto"
+	_protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	_protoregistry "google.golang.org/protobuf/reflect/protoregistry"
+	_dynamicpb "google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	inProcessBufferSize = 256 * 1024
+)
+
+var (
+	ErrNoServer            = errors.New("gateway: both HTTP and GRPC server must be set")
+	ErrUnsupportedTemplate = errors.New("gateway: unsupported path template")
+	ErrUnsupportedMethod   = errors.New("gateway: unsupported HTTP method")
+
+	errBodyTooLarge = errors.New("request body too large")
+)
+
+// Gateway expose unary methods of services registered on GRPC server as JSON endpoints of HTTP server.
+// Methods annotated with google.api.http are exposed as annotated, the others at POST /pkg.Service/Method
+// with request as body. Services are found by their descriptors, so they must be registered before New.
+type Gateway struct {
+	conn          _grpc.ClientConnInterface
+	inProcess     *_grpc.ClientConn
+	lis           *_bufconn.Listener
+	headerMatcher func(header string) (string, bool)
+	marshal       _protojson.MarshalOptions
+	unmarshal     _protojson.UnmarshalOptions
+	routes        []Route
+	skipped       []SkippedRoute
+	maxBodySize   int64
+	logger        *log.Logger
+}
+
+type Opts struct {
+	// GRPC server whose services are exposed.
+	GRPC *_grpcserver.Server
+
+	// HTTP server endpoints are registered on, along with its other routes.
+	HTTP *_httpserver.Server
+
+	// Conn optional, connection calls are sent through, e.g. stub of GRPC. Default is in-process connection to GRPC,
+	// which must not have TLS then.
+	Conn _grpc.ClientConnInterface
+
+	// Files optional, descriptors of services. Default is protoregistry.GlobalFiles, where generated code registers them.
+	Files *_protoregistry.Files
+
+	// AnnotatedOnly if true then only methods annotated with google.api.http are exposed.
+	AnnotatedOnly bool
+
+	// Strict if true then New fails on binding of unsupported template or method, e.g. custom verb /v1/{name}:cancel
+	// or multi-segment variable {name=projects/*}. Otherwise such binding is skipped and logged, see Skipped.
+	Strict bool
+
+	// HeaderMatcher optional, map request header into metadata key, header is dropped if it returns false.
+	// Default is DefaultHeaderMatcher, passing only allow-listed and Grpc-Metadata- prefixed headers.
+	HeaderMatcher func(header string) (string, bool)
+
+	// UseProtoNames respond fields with their proto names, e.g. item_id, instead of lowerCamelCase JSON names.
+	UseProtoNames bool
+	// EmitUnpopulated respond fields having zero values as well.
+	EmitUnpopulated bool
+	// DiscardUnknown ignore unknown fields of request body instead of responding 400.
+	DiscardUnknown bool
+
+	// MaxBodySize optional, limit of request body size in bytes, larger body is responded 413 with InvalidArgument status.
+	// Default is MaxRecvMsgSize of GRPC, as larger request couldn't be received anyway.
+	MaxBodySize int64
+}
+
+// Route exposed method.
+type Route struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	GRPCMethod string `json:"grpc_method"`
+}
+
+// SkippedRoute binding of method not exposed because it's not supported, Path is its template.
+type SkippedRoute struct {
+	Route
+	Reason string `json:"reason"`
+}
+
+func New(opts *Opts) (*Gateway, error) {
+	if opts.GRPC == nil || opts.HTTP == nil {
+		return nil, ErrNoServer
+	}
+	gw := &Gateway{
+		conn:          opts.Conn,
+		headerMatcher: opts.HeaderMatcher,
+		marshal: _protojson.MarshalOptions{
+			UseProtoNames:   opts.UseProtoNames,
+			EmitUnpopulated: opts.EmitUnpopulated,
+		},
+		unmarshal:   _protojson.UnmarshalOptions{DiscardUnknown: opts.DiscardUnknown},
+		maxBodySize: opts.MaxBodySize,
+		logger:      log.New(os.Stderr, "", 0),
+	}
+	if gw.headerMatcher == nil {
+		gw.headerMatcher = DefaultHeaderMatcher
+	}
+	if gw.maxBodySize <= 0 {
+		gw.maxBodySize = int64(opts.GRPC.MaxRecvMsgSize())
+	}
+	files := opts.Files
+	if files == nil {
+		files = _protoregistry.GlobalFiles
+	}
+
+	// endpoints are built before any of them is registered, so nothing is registered on error.
+	endpoints, err := gw.endpoints(opts.GRPC.Server(), files, opts.AnnotatedOnly, opts.Strict)
+	if err != nil {
+		return nil, err
+	}
+	if gw.conn == nil {
+		if err := gw.dialInProcess(opts.GRPC.Server()); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range endpoints {
+		switch e.route.Method {
+		case http.MethodGet:
+			opts.HTTP.GET(e.route.Path, e.serve)
+		case http.MethodPost:
+			opts.HTTP.POST(e.route.Path, e.serve)
+		case http.MethodPut:
+			opts.HTTP.PUT(e.route.Path, e.serve)
+		case http.MethodPatch:
+			opts.HTTP.PATCH(e.route.Path, e.serve)
+		case http.MethodDelete:
+			opts.HTTP.DELETE(e.route.Path, e.serve)
+		case http.MethodHead:
+			opts.HTTP.HEAD(e.route.Path, e.serve)
+		case http.MethodOptions:
+			opts.HTTP.OPTIONS(e.route.Path, e.serve)
+		}
+		gw.routes = append(gw.routes, e.route)
+	}
+	return gw, nil
+}
+
+// dialInProcess serve srv on in-memory listener and connect to it, so calls go through its interceptors.
+func (gw *Gateway) dialInProcess(srv *_grpc.Server) error {
+	gw.lis = _bufconn.Listen(inProcessBufferSize)
+	go srv.Serve(gw.lis)
+	conn, err := _grpc.Dial("in-process",
+		_grpc.WithInsecure(),
+		_grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return gw.lis.Dial()
+		}),
+	)
+	if err != nil {
+		gw.lis.Close()
+		return err
+	}
+	gw.inProcess, gw.conn = conn, conn
+	return nil
+}
+
+// Routes return exposed methods in registration order.
+func (gw *Gateway) Routes() []Route {
+	routes := make([]Route, len(gw.routes))
+	copy(routes, gw.routes)
+	return routes
+}
+
+// Skipped return bindings not exposed because they're not supported, empty if Opts.Strict.
+func (gw *Gateway) Skipped() []SkippedRoute {
+	skipped := make([]SkippedRoute, len(gw.skipped))
+	copy(skipped, gw.skipped)
+	return skipped
+}
+
+// Close close in-process connection, routes stay registered and respond 499 afterwards.
+func (gw *Gateway) Close() error {
+	if gw.inProcess == nil {
+		return nil
+	}
+	err := gw.inProcess.Close()
+	gw.lis.Close()
+	return err
+}
+
+func (gw *Gateway) endpoints(srv *_grpc.Server, files *_protoregistry.Files, annotatedOnly bool, strict bool) ([]*endpoint, error) {
+	info := srv.GetServiceInfo()
+	services := make([]string, 0, len(info))
+	for name := range info {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	var endpoints []*endpoint
+	for _, name := range services {
+		d, err := files.FindDescriptorByName(_protoreflect.FullName(name))
+		if err != nil {
+			// service generated without descriptors can't be transcoded.
+			continue
+		}
+		sd, ok := d.(_protoreflect.ServiceDescriptor)
+		if !ok {
+			continue
+		}
+		methods := sd.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			md := methods.Get(i)
+			if md.IsStreamingClient() || md.IsStreamingServer() {
+				continue
+			}
+			rule, _ := _proto.GetExtension(md.Options(), _annotations.E_Http).(*_annotations.HttpRule)
+			if rule == nil || rule.GetPattern() == nil {
+				if annotatedOnly {
+					continue
+				}
+				rule = &_annotations.HttpRule{
+					Pattern: &_annotations.HttpRule_Post{Post: fmt.Sprintf("/%s/%s", sd.FullName(), md.Name())},
+					Body:    "*",
+				}
+			}
+			for _, r := range append([]*_annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+				fullMethod := fmt.Sprintf("/%s/%s", sd.FullName(), md.Name())
+				e, err := gw.endpoint(fullMethod, md, r)
+				if err != nil {
+					unsupported := errors.Is(err, ErrUnsupportedTemplate) || errors.Is(err, ErrUnsupportedMethod)
+					if strict || !unsupported {
+						return nil, err
+					}
+					gw.skip(fullMethod, r, err)
+					continue
+				}
+				endpoints = append(endpoints, e)
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// endpoint JSON endpoint of method bound by HTTP rule.
+type endpoint struct {
+	gw           *Gateway
+	route        Route
+	input        _protoreflect.MessageDescriptor
+	output       _protoreflect.MessageDescriptor
+	params       []pathParam
+	body         string
+	responseBody _protoreflect.FieldDescriptor
+}
+
+// skip record unsupported binding of method.
+func (gw *Gateway) skip(fullMethod string, rule *_annotations.HttpRule, err error) {
+	method, template := httpPattern(rule)
+	gw.skipped = append(gw.skipped, SkippedRoute{
+		Route:  Route{Method: method, Path: template, GRPCMethod: fullMethod},
+		Reason: err.Error(),
+	})
+	gw.logger.Printf("%s | gateway | SKIP | %s %s | %s | %v\n", time.Now().Format(time.RFC3339), method, template, fullMethod, err)
+}
+
+// httpPattern return HTTP method and path template of rule.
+func httpPattern(rule *_annotations.HttpRule) (method string, template string) {
+	switch p := rule.GetPattern().(type) {
+	case *_annotations.HttpRule_Get:
+		method, template = http.MethodGet, p.Get
+	case *_annotations.HttpRule_Post:
+		method, template = http.MethodPost, p.Post
+	case *_annotations.HttpRule_Put:
+		method, template = http.MethodPut, p.Put
+	case *_annotations.HttpRule_Patch:
+		method, template = http.MethodPatch, p.Patch
+	case *_annotations.HttpRule_Delete:
+		method, template = http.MethodDelete, p.Delete
+	case *_annotations.HttpRule_Custom:
+		method, template = strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	}
+	return method, template
+}
+
+func (gw *Gateway) endpoint(fullMethod string, md _protoreflect.MethodDescriptor, rule *_annotations.HttpRule) (*endpoint, error) {
+	method, template := httpPattern(rule)
+	if _, ok := rule.GetPattern().(*_annotations.HttpRule_Custom); ok && method != http.MethodHead && method != http.MethodOptions {
+		return nil, fmt.Errorf("%w: %s of %s", ErrUnsupportedMethod, method, fullMethod)
+	}
+	path, params, err := parseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	e := &endpoint{
+		gw:     gw,
+		route:  Route{Method: method, Path: path, GRPCMethod: fullMethod},
+		input:  md.Input(),
+		output: md.Output(),
+		params: params,
+		body:   rule.GetBody(),
+	}
+	if e.body != "" && e.body != "*" && e.input.Fields().ByName(_protoreflect.Name(e.body)) == nil {
+		return nil, fmt.Errorf("gateway: body field %s not found in %s", e.body, e.input.FullName())
+	}
+	if rb := rule.GetResponseBody(); rb != "" {
+		if e.responseBody = e.output.Fields().ByName(_protoreflect.Name(rb)); e.responseBody == nil {
+			return nil, fmt.Errorf("gateway: response body field %s not found in %s", rb, e.output.FullName())
+		}
+	}
+	return e, nil
+}
+
+func (e *endpoint) serve(w http.ResponseWriter, r *http.Request) {
+	req := newMessage(e.input)
+	if err := e.decode(w, r, req); err != nil {
+		// too large body is 413 rather than 400 of InvalidArgument, so it's not retried as is.
+		if errors.Is(err, errBodyTooLarge) {
+			respondStatus(w, r, http.StatusRequestEntityTooLarge, _status.New(_codes.InvalidArgument, err.Error()))
+			return
+		}
+		respondError(w, r, _status.Error(_codes.InvalidArgument, err.Error()))
+		return
+	}
+	resp := newMessage(e.output)
+	var header _metadata.MD
+	// grpc codec expects messages of github.com/golang/protobuf API.
+	err := e.gw.conn.Invoke(e.gw.outgoing(r), e.route.GRPCMethod, _protov1.MessageV1(req.Interface()), _protov1.MessageV1(resp.Interface()), _grpc.Header(&header))
+	for k, v := range header {
+		for _, s := range v {
+			w.Header().Add(MetadataHeaderPrefix+k, s)
+		}
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	body, err := e.encode(resp)
+	if err != nil {
+		respondError(w, r, _status.Error(_codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	_httpserver.Response(w, r, http.StatusOK, body)
+}
+
+// decode fill req from body, query params and path params, in that order.
+// Query params are ignored if the whole request is the body.
+func (e *endpoint) decode(w http.ResponseWriter, r *http.Request, req _protoreflect.Message) error {
+	if e.body != "" && r.Body != nil {
+		if r.ContentLength > e.gw.maxBodySize {
+			return errBodyTooLarge
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, e.gw.maxBodySize))
+		if err != nil {
+			if int64(len(body)) >= e.gw.maxBodySize {
+				return errBodyTooLarge
+			}
+			return err
+		}
+		if body = bytes.TrimSpace(body); len(body) > 0 {
+			if e.body != "*" {
+				// body of field is unmarshalled as the only field of request.
+				body = []byte(fmt.Sprintf("{%q:%s}", e.body, body))
+			}
+			if err := e.gw.unmarshal.Unmarshal(body, req.Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	if e.body != "*" {
+		query, err := url.ParseQuery(_httpserver.RawQuery(r))
+		if err != nil {
+			return err
+		}
+		for k, v := range query {
+			if err := setField(req, k, v, true); err != nil {
+				return err
+			}
+		}
+	}
+	// path params are added into query by router, after the ones sent by client.
+	query := r.URL.Query()
+	for _, p := range e.params {
+		v := query[p.field]
+		if len(v) == 0 {
+			continue
+		}
+		value := v[len(v)-1]
+		if p.catchAll {
+			value = strings.TrimPrefix(value, "/")
+		}
+		if err := setField(req, p.field, []string{value}, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode marshal resp, or only its response body field.
+func (e *endpoint) encode(resp _protoreflect.Message) ([]byte, error) {
+	if e.responseBody == nil {
+		return e.gw.marshal.Marshal(resp.Interface())
+	}
+	fd := e.responseBody
+	if fd.Kind() == _protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+		return e.gw.marshal.Marshal(resp.Get(fd).Message().Interface())
+	}
+	// non-message field is picked from the whole response.
+	opts := e.gw.marshal
+	opts.UseProtoNames, opts.EmitUnpopulated = true, true
+	b, err := opts.Marshal(resp.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields[string(fd.Name())], nil
+}
+
+// outgoing return request context with metadata of matched headers and trace context of HTTP server span.
+func (gw *Gateway) outgoing(r *http.Request) context.Context {
+	md := _metadata.MD{}
+	for k, v := range r.Header {
+		if key, ok := gw.headerMatcher(k); ok {
+			md.Append(key, v...)
+		}
+	}
+	_trace.Inject(r.Context(), metadataCarrier(md))
+	return _metadata.NewOutgoingContext(r.Context(), md)
+}
+
+// newMessage return message of generated type if registered, otherwise dynamic one.
+func newMessage(d _protoreflect.MessageDescriptor) _protoreflect.Message {
+	if mt, err := _protoregistry.GlobalTypes.FindMessageByName(d.FullName()); err == nil {
+		return mt.New()
+	}
+	return _dynamicpb.NewMessage(d)
+}
+
+// metadataCarrier trace.Carrier of grpc metadata.
+type metadataCarrier _metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := _metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	_metadata.MD(c).Set(key, value)
+}
@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	_httpserver "github.com/mfathirirhas/godevkit/http/server"
+	_codes "google.golang.org/grpc/codes"
+	_status "google.golang.org/grpc/status"
+	_protojson "google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// MetadataHeaderPrefix prefix of HTTP headers carrying metadata, request ones are sent as metadata of calls
+	// by DefaultHeaderMatcher and response header metadata of calls is responded with it.
+	MetadataHeaderPrefix = "Grpc-Metadata-"
+
+	contentTypeJSON = "application/json"
+)
+
+// HTTPStatus return HTTP status code of gRPC code.
+func HTTPStatus(code _codes.Code) int {
+	switch code {
+	case _codes.OK:
+		return http.StatusOK
+	case _codes.Canceled:
+		return 499 // client closed request
+	case _codes.InvalidArgument, _codes.FailedPrecondition, _codes.OutOfRange:
+		return http.StatusBadRequest
+	case _codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case _codes.NotFound:
+		return http.StatusNotFound
+	case _codes.AlreadyExists, _codes.Aborted:
+		return http.StatusConflict
+	case _codes.PermissionDenied:
+		return http.StatusForbidden
+	case _codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case _codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case _codes.Unimplemented:
+		return http.StatusNotImplemented
+	case _codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// respondError respond err as JSON of google.rpc.Status, e.g. {"code":5,"message":"item not found","details":[]},
+// with HTTP status code of its code.
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	st := _status.Convert(err)
+	respondStatus(w, r, HTTPStatus(st.Code()), st)
+}
+
+// respondStatus respond st as JSON of google.rpc.Status with HTTP status code.
+func respondStatus(w http.ResponseWriter, r *http.Request, code int, st *_status.Status) {
+	body, mErr := _protojson.Marshal(st.Proto())
+	if mErr != nil {
+		// details of unknown types can't be marshalled.
+		body, _ = _protojson.Marshal(_status.New(st.Code(), st.Message()).Proto())
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	_httpserver.Response(w, r, code, body)
+}
+
+// forwardedHeaders request headers DefaultHeaderMatcher pass as they are.
+var forwardedHeaders = map[string]bool{
+	"authorization": true,
+	"request-id":    true,
+	"x-request-id":  true,
+}
+
+// connectionHeaders headers of HTTP connection rather than of request, invalid as metadata.
+var connectionHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"content-length":    true,
+	"content-type":      true,
+	"host":              true,
+}
+
+// DefaultHeaderMatcher pass only Authorization, Request-Id and X-Request-Id headers as lower-cased metadata keys,
+// and headers prefixed with MetadataHeaderPrefix without it, e.g. Grpc-Metadata-Tenant as tenant.
+// The others, e.g. Cookie, are dropped so they don't reach services unintentionally, use Opts.HeaderMatcher to pass them.
+func DefaultHeaderMatcher(header string) (string, bool) {
+	key := strings.ToLower(header)
+	if forwardedHeaders[key] {
+		return key, true
+	}
+	prefix := strings.ToLower(MetadataHeaderPrefix)
+	if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+		key = key[len(prefix):]
+		// connection and grpc- keys are reserved.
+		if connectionHeaders[key] || strings.HasPrefix(key, "grpc-") {
+			return "", false
+		}
+		return key, true
+	}
+	return "", false
+}
@@ -0,0 +1,154 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_protojson "google.golang.org/protobuf/encoding/protojson"
+	_protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pathParam variable of path template bound to request field.
+type pathParam struct {
+	field    string
+	catchAll bool
+}
+
+// parseTemplate convert path template of google.api.http rule into router path, e.g. /v1/items/{id} into /v1/items/:id.
+// Variables are single segment, {field} or {field=*}, or trailing catch-all, {field=**}. Custom verbs are not supported.
+func parseTemplate(template string) (string, []pathParam, error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedTemplate, template)
+	}
+	segments := strings.Split(template[1:], "/")
+	var params []pathParam
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, "{") {
+			if seg == "" || strings.ContainsAny(seg, ":*{}") {
+				return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedTemplate, template)
+			}
+			continue
+		}
+		if !strings.HasSuffix(seg, "}") {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedTemplate, template)
+		}
+		field, pattern := seg[1:len(seg)-1], "*"
+		if j := strings.Index(field, "="); j >= 0 {
+			field, pattern = field[:j], field[j+1:]
+		}
+		switch {
+		case field == "":
+			return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedTemplate, template)
+		case pattern == "*":
+			segments[i] = ":" + field
+			params = append(params, pathParam{field: field})
+		case pattern == "**" && i == len(segments)-1:
+			segments[i] = "*" + field
+			params = append(params, pathParam{field: field, catchAll: true})
+		default:
+			return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedTemplate, template)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), params, nil
+}
+
+// setField set field at path, e.g. item.id, of msg from string values, repeated field gets all of them
+// and the others the last one. Unknown field is ignored if ignoreUnknown.
+func setField(msg _protoreflect.Message, path string, values []string, ignoreUnknown bool) error {
+	if len(values) == 0 {
+		return nil
+	}
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fields := msg.Descriptor().Fields()
+		fd := fields.ByName(_protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			if ignoreUnknown {
+				return nil
+			}
+			return fmt.Errorf("no field %s in %s", path, msg.Descriptor().FullName())
+		}
+		if i < len(names)-1 {
+			if fd.Kind() != _protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+				return fmt.Errorf("field %s of %s is not a message", name, msg.Descriptor().FullName())
+			}
+			msg = msg.Mutable(fd).Message()
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			return fmt.Errorf("map field %s can't be set from string", path)
+		case fd.IsList():
+			list := msg.Mutable(fd).List()
+			for _, s := range values {
+				v, err := parseValue(fd, list.NewElement, s)
+				if err != nil {
+					return fmt.Errorf("field %s: %w", path, err)
+				}
+				list.Append(v)
+			}
+		default:
+			v, err := parseValue(fd, func() _protoreflect.Value { return msg.NewField(fd) }, values[len(values)-1])
+			if err != nil {
+				return fmt.Errorf("field %s: %w", path, err)
+			}
+			msg.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+// parseValue parse s as value of fd, message values, e.g. google.protobuf.Timestamp, are parsed as their JSON.
+func parseValue(fd _protoreflect.FieldDescriptor, newValue func() _protoreflect.Value, s string) (_protoreflect.Value, error) {
+	switch fd.Kind() {
+	case _protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return _protoreflect.ValueOfBool(v), err
+	case _protoreflect.Int32Kind, _protoreflect.Sint32Kind, _protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return _protoreflect.ValueOfInt32(int32(v)), err
+	case _protoreflect.Int64Kind, _protoreflect.Sint64Kind, _protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return _protoreflect.ValueOfInt64(v), err
+	case _protoreflect.Uint32Kind, _protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return _protoreflect.ValueOfUint32(uint32(v)), err
+	case _protoreflect.Uint64Kind, _protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return _protoreflect.ValueOfUint64(v), err
+	case _protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return _protoreflect.ValueOfFloat32(float32(v)), err
+	case _protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return _protoreflect.ValueOfFloat64(v), err
+	case _protoreflect.StringKind:
+		return _protoreflect.ValueOfString(s), nil
+	case _protoreflect.BytesKind:
+		v, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			v, err = base64.URLEncoding.DecodeString(s)
+		}
+		return _protoreflect.ValueOfBytes(v), err
+	case _protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(_protoreflect.Name(s)); ev != nil {
+			return _protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		return _protoreflect.ValueOfEnum(_protoreflect.EnumNumber(v)), err
+	case _protoreflect.MessageKind, _protoreflect.GroupKind:
+		v := newValue()
+		if err := _protojson.Unmarshal([]byte(s), v.Message().Interface()); err != nil {
+			if err := _protojson.Unmarshal([]byte(strconv.Quote(s)), v.Message().Interface()); err != nil {
+				return v, err
+			}
+		}
+		return v, nil
+	}
+	return _protoreflect.Value{}, fmt.Errorf("unsupported kind %s", fd.Kind())
+}
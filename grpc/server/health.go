@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+
+	_grpc "google.golang.org/grpc"
+	_health "google.golang.org/grpc/health"
+	_healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	healthWatchMethod = "/grpc.health.v1.Health/Watch"
+)
+
+// SetServingStatus set serving status reported by grpc.health.v1 service, empty service is the whole server.
+// The whole server is serving once created. No-op if health service is disabled.
+func (g *Server) SetServingStatus(service string, serving bool) {
+	if g.health == nil {
+		return
+	}
+	status := _healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = _healthpb.HealthCheckResponse_SERVING
+	}
+	g.health.SetServingStatus(service, status)
+}
+
+// Health return health service, nil if it's disabled.
+func (g *Server) Health() *_health.Server {
+	return g.health
+}
+
+// Drain report every service as not serving and end health watches, without stopping server.
+// For server served by other HTTP/2 server, see package mux, Shutdown does it otherwise.
+func (g *Server) Drain() {
+	g.notServing()
+}
+
+// notServing report every service as not serving, so load balancers drain server before it stops,
+// and end health watches.
+func (g *Server) notServing() {
+	if g.health != nil {
+		g.health.Shutdown()
+	}
+	g.shutdownOnce.Do(func() {
+		close(g.shutdown)
+	})
+}
+
+// streamHealthWatch end health watch once server is shutting down, otherwise it's open until client ends it,
+// keeping graceful stop waiting. Watcher is sent NOT_SERVING before the watch ends.
+func (g *Server) streamHealthWatch(srv interface{}, ss _grpc.ServerStream, info *_grpc.StreamServerInfo, handler _grpc.StreamHandler) error {
+	if info.FullMethod != healthWatchMethod {
+		return handler(srv, ss)
+	}
+	ctx, cancel := context.WithCancel(ss.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-g.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	ws := &watchStream{ServerStream: ss, ctx: ctx, last: -1}
+	err := handler(srv, ws)
+	if ss.Context().Err() != nil {
+		return err
+	}
+	select {
+	case <-g.shutdown:
+		if ws.last != _healthpb.HealthCheckResponse_NOT_SERVING {
+			ss.SendMsg(&_healthpb.HealthCheckResponse{Status: _healthpb.HealthCheckResponse_NOT_SERVING})
+		}
+		return nil
+	default:
+		return err
+	}
+}
+
+// watchStream health watch stream cancelled once server is shutting down.
+type watchStream struct {
+	_grpc.ServerStream
+	ctx  context.Context
+	last _healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if resp, ok := m.(*_healthpb.HealthCheckResponse); ok && err == nil {
+		s.last = resp.Status
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	_uuid "github.com/google/uuid"
+	_log "github.com/mfathirirhas/godevkit/log"
+	_grpc "google.golang.org/grpc"
+	_codes "google.golang.org/grpc/codes"
+	_metadata "google.golang.org/grpc/metadata"
+	_peer "google.golang.org/grpc/peer"
+	_status "google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataRequestID metadata key of request id, x-request-id is accepted as well.
+	MetadataRequestID = "request-id"
+
+	metadataXRequestID = "x-request-id"
+)
+
+// unaryInterceptors built-in interceptors followed by custom ones, outermost first.
+func (g *Server) unaryInterceptors(opts *Opts) []_grpc.UnaryServerInterceptor {
+	interceptors := []_grpc.UnaryServerInterceptor{unaryRequestID, g.unaryTrace, unaryRecover}
+	if opts.EnableLogger {
+		interceptors = append(interceptors, unaryLog)
+	}
+	if opts.Timeout > 0 || len(opts.MethodTimeouts) > 0 {
+		interceptors = append(interceptors, unaryTimeout(opts.Timeout, opts.MethodTimeouts))
+	}
+	return append(interceptors, opts.UnaryInterceptors...)
+}
+
+// streamInterceptors built-in interceptors followed by custom ones, outermost first.
+func (g *Server) streamInterceptors(opts *Opts) []_grpc.StreamServerInterceptor {
+	interceptors := []_grpc.StreamServerInterceptor{streamRequestID, g.streamTrace, streamRecover}
+	if opts.EnableLogger {
+		interceptors = append(interceptors, streamLog)
+	}
+	if opts.Timeout > 0 || len(opts.MethodTimeouts) > 0 {
+		interceptors = append(interceptors, streamTimeout(opts.Timeout, opts.MethodTimeouts))
+	}
+	interceptors = append(interceptors, opts.StreamInterceptors...)
+	if !opts.DisableHealthCheck {
+		interceptors = append(interceptors, g.streamHealthWatch)
+	}
+	return interceptors
+}
+
+// RequestID return request id of incoming call, set by client in request-id metadata or generated.
+func RequestID(ctx context.Context) string {
+	md, _ := _metadata.FromIncomingContext(ctx)
+	if v := md.Get(MetadataRequestID); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// withRequestID set request-id of incoming metadata if empty, so handlers and stubs called by them see it,
+// and send it back in response header.
+func withRequestID(ctx context.Context) context.Context {
+	md, _ := _metadata.FromIncomingContext(ctx)
+	id := ""
+	if v := md.Get(MetadataRequestID); len(v) > 0 && v[0] != "" {
+		id = v[0]
+	} else {
+		if v := md.Get(metadataXRequestID); len(v) > 0 && v[0] != "" {
+			id = v[0]
+		} else {
+			id = _uuid.New().String()
+		}
+		md = md.Copy()
+		md.Set(MetadataRequestID, id)
+		ctx = _metadata.NewIncomingContext(ctx, md)
+	}
+	_grpc.SetHeader(ctx, _metadata.Pairs(MetadataRequestID, id))
+	return ctx
+}
+
+func unaryRequestID(ctx context.Context, req interface{}, info *_grpc.UnaryServerInfo, handler _grpc.UnaryHandler) (interface{}, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+func streamRequestID(srv interface{}, ss _grpc.ServerStream, info *_grpc.StreamServerInfo, handler _grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
+
+// recovered log panic along with its stack and return codes.Internal error.
+func recovered(ctx context.Context, method string, p interface{}) error {
+	_log.WithContext(ctx).WithFields(map[string]interface{}{
+		"method":     method,
+		"request_id": RequestID(ctx),
+	}).Error(fmt.Errorf("%v", p), "grpcserver PANIC")
+	debug.PrintStack()
+	return _status.Error(_codes.Internal, "grpcserver got panic")
+}
+
+func unaryRecover(ctx context.Context, req interface{}, info *_grpc.UnaryServerInfo, handler _grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(ctx, info.FullMethod, p)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func streamRecover(srv interface{}, ss _grpc.ServerStream, info *_grpc.StreamServerInfo, handler _grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(ss.Context(), info.FullMethod, p)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// logCall log finished call, at error level if it's server fault and at warn level if it's client fault.
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := _status.Code(err)
+	fields := map[string]interface{}{
+		"method":     method,
+		"code":       code.String(),
+		"elapsed":    time.Since(start).String(),
+		"request_id": RequestID(ctx),
+	}
+	if p, ok := _peer.FromContext(ctx); ok {
+		fields["peer"] = p.Addr.String()
+	}
+	entry := _log.WithContext(ctx).WithFields(fields)
+	switch code {
+	case _codes.OK:
+		entry.Info("grpcserver")
+	case _codes.Unknown, _codes.DeadlineExceeded, _codes.Unimplemented, _codes.Internal, _codes.Unavailable, _codes.DataLoss:
+		entry.Error(err, "grpcserver")
+	default:
+		entry.WithFields(map[string]interface{}{"err": err}).Warn("grpcserver")
+	}
+}
+
+func unaryLog(ctx context.Context, req interface{}, info *_grpc.UnaryServerInfo, handler _grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamLog(srv interface{}, ss _grpc.ServerStream, info *_grpc.StreamServerInfo, handler _grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logCall(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+// methodTimeout return timeout of full method, e.g. /pkg.Service/Method, or of its service, e.g. /pkg.Service, or fallback.
+func methodTimeout(method string, fallback time.Duration, timeouts map[string]time.Duration) time.Duration {
+	if d, ok := timeouts[method]; ok {
+		return d
+	}
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		if d, ok := timeouts[method[:i]]; ok {
+			return d
+		}
+	}
+	return fallback
+}
+
+func unaryTimeout(fallback time.Duration, timeouts map[string]time.Duration) _grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *_grpc.UnaryServerInfo, handler _grpc.UnaryHandler) (interface{}, error) {
+		if d := methodTimeout(info.FullMethod, fallback, timeouts); d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		resp, err := handler(ctx, req)
+		return resp, deadlineExceeded(ctx, err)
+	}
+}
+
+func streamTimeout(fallback time.Duration, timeouts map[string]time.Duration) _grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss _grpc.ServerStream, info *_grpc.StreamServerInfo, handler _grpc.StreamHandler) error {
+		if d := methodTimeout(info.FullMethod, fallback, timeouts); d > 0 {
+			ctx, cancel := context.WithTimeout(ss.Context(), d)
+			defer cancel()
+			ss = &serverStream{ServerStream: ss, ctx: ctx}
+		}
+		return deadlineExceeded(ss.Context(), handler(srv, ss))
+	}
+}
+
+// deadlineExceeded return codes.DeadlineExceeded instead of unknown error once ctx deadline is exceeded, e.g. ctx.Err().
+func deadlineExceeded(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded && _status.Code(err) == _codes.Unknown {
+		return _status.Error(_codes.DeadlineExceeded, err.Error())
+	}
+	return err
+}
+
+// serverStream server stream with its own context.
+type serverStream struct {
+	_grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
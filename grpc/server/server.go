@@ -1,48 +1,355 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 
+	_certs "github.com/mfathirirhas/godevkit/certs"
+	_trace "github.com/mfathirirhas/godevkit/trace"
 	_reuseport "github.com/valyala/fasthttp/reuseport"
 	_grpc "google.golang.org/grpc"
+	_credentials "google.golang.org/grpc/credentials"
+	_health "google.golang.org/grpc/health"
+	_healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	_keepalive "google.golang.org/grpc/keepalive"
 	_reflection "google.golang.org/grpc/reflection"
 )
 
+const (
+	defaultShutdownTimeout = 10 * time.Second
+	defaultMaxRecvMsgSize  = 4 << 20 // grpc default
+)
+
 type Server struct {
-	srv     *_grpc.Server
-	port    uint16
-	errChan chan error
+	srv             *_grpc.Server
+	port            uint16
+	unixSocket      string
+	listener        net.Listener
+	shutdownTimeout time.Duration
+	errChan         chan error
+	tracer          *_trace.Tracer
+	certs           *_certs.Reloader
+	health          *_health.Server
+	maxRecvMsgSize  int
+	shutdown        chan struct{}
+	shutdownOnce    sync.Once
+
+	mu  sync.Mutex
+	lis net.Listener
 }
 
 type Opts struct {
+	// Port listened on all IPv4 interfaces with SO_REUSEPORT. Ignored if UnixSocket or Listener is set.
 	Port uint16
+
+	// UnixSocket optional, path of unix socket to listen on, stale socket file is removed.
+	UnixSocket string
+
+	// Listener optional, custom listener, e.g. shared with other server or created by systemd socket activation.
+	Listener net.Listener
+
+	// DisableReflection disable server reflection service, e.g. in production. Reflection is enabled by default.
+	DisableReflection bool
+
+	// DisableHealthCheck disable grpc.health.v1 service. Health service is enabled by default,
+	// reporting serving until Shutdown, see SetServingStatus.
+	DisableHealthCheck bool
+
+	// ShutdownTimeout how long Run waits for in-flight calls once its ctx is done, before they are cancelled.
+	// Default is 10 seconds.
+	ShutdownTimeout time.Duration
+
+	// Tracer optional, trace context of incoming traceparent metadata is continued by server span of each call.
+	// Default is trace.Default().
+	Tracer *_trace.Tracer
+
+	// TLS optional, if set then connections are secured with TLS, and with mTLS if ClientCAFiles is set.
+	TLS *TLSOpts
+
+	// EnableLogger enable access logging of each call through godevkit/log.
+	EnableLogger bool
+
+	// Timeout optional, deadline of each call, shorter deadline set by client is kept.
+	Timeout time.Duration
+	// MethodTimeouts optional, deadline of calls by full method, e.g. /pkg.Service/Method, or by service, e.g. /pkg.Service.
+	// Overrides Timeout.
+	MethodTimeouts map[string]time.Duration
+
+	// Keepalive optional, pinging of idle connections and limits of clients pinging and connection age.
+	Keepalive *KeepaliveOpts
+
+	// MaxConcurrentStreams optional, limit of concurrent streams, i.e. calls, of each connection. Default is unlimited.
+	MaxConcurrentStreams uint32
+
+	// MaxRecvMsgSize and MaxSendMsgSize optional, limit of message size in bytes.
+	// Default is 4MB for received and unlimited for sent messages.
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+
+	// UnaryInterceptors and StreamInterceptors optional, executed in the order they are passed,
+	// after request id, tracing, panic recovery, logging and timeout.
+	UnaryInterceptors  []_grpc.UnaryServerInterceptor
+	StreamInterceptors []_grpc.StreamServerInterceptor
 }
 
-func New(opts *Opts) *Server {
-	srv := _grpc.NewServer()
-	return &Server{
-		srv:  srv,
-		port: opts.Port,
+// TLSOpts TLS options of server. Files are PEM and reloaded once they change on disk, see certs.WriteSelfSigned for local testing.
+type TLSOpts struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFiles optional, if set then clients must present certificate signed by one of them.
+	ClientCAFiles []string
+	// ClientCertOptional if true then clients without certificate are accepted, presented ones are still verified.
+	ClientCertOptional bool
+
+	// MinVersion minimum TLS version, e.g. tls.VersionTLS13. Default is TLS 1.2.
+	MinVersion uint16
+
+	// ReloadInterval how often files are checked for changes. Default is 1 minute, negative disables reload.
+	ReloadInterval time.Duration
+}
+
+// KeepaliveOpts keepalive options of server, zero values are grpc defaults.
+type KeepaliveOpts struct {
+	// Time after which server pings idle connection. Default is 2 hours.
+	Time time.Duration
+	// Timeout of ping before connection is closed. Default is 20 seconds.
+	Timeout time.Duration
+
+	// MinTime minimum interval of clients pings, connections pinging more often are closed. Default is 5 minutes.
+	MinTime time.Duration
+	// PermitWithoutStream allow clients to ping without active calls, otherwise their connections are closed.
+	PermitWithoutStream bool
+
+	// MaxConnectionIdle after which idle connection is closed. Default is infinity.
+	MaxConnectionIdle time.Duration
+	// MaxConnectionAge after which connection is closed, so clients reconnect, e.g. to newly scaled servers.
+	// Default is infinity.
+	MaxConnectionAge time.Duration
+	// MaxConnectionAgeGrace given to in-flight calls once connection reaches MaxConnectionAge. Default is infinity.
+	MaxConnectionAgeGrace time.Duration
+}
+
+func New(opts *Opts) (*Server, error) {
+	g := &Server{
+		port:            opts.Port,
+		unixSocket:      opts.UnixSocket,
+		listener:        opts.Listener,
+		shutdownTimeout: opts.ShutdownTimeout,
+		errChan:         make(chan error, 1),
+		tracer:          opts.Tracer,
+		maxRecvMsgSize:  opts.MaxRecvMsgSize,
+		shutdown:        make(chan struct{}),
+	}
+	if g.shutdownTimeout <= 0 {
+		g.shutdownTimeout = defaultShutdownTimeout
+	}
+	if g.maxRecvMsgSize <= 0 {
+		g.maxRecvMsgSize = defaultMaxRecvMsgSize
+	}
+	serverOpts := []_grpc.ServerOption{
+		_grpc.ChainUnaryInterceptor(g.unaryInterceptors(opts)...),
+		_grpc.ChainStreamInterceptor(g.streamInterceptors(opts)...),
+	}
+	if k := opts.Keepalive; k != nil {
+		serverOpts = append(serverOpts,
+			_grpc.KeepaliveParams(_keepalive.ServerParameters{
+				Time:                  k.Time,
+				Timeout:               k.Timeout,
+				MaxConnectionIdle:     k.MaxConnectionIdle,
+				MaxConnectionAge:      k.MaxConnectionAge,
+				MaxConnectionAgeGrace: k.MaxConnectionAgeGrace,
+			}),
+			_grpc.KeepaliveEnforcementPolicy(_keepalive.EnforcementPolicy{
+				MinTime:             k.MinTime,
+				PermitWithoutStream: k.PermitWithoutStream,
+			}),
+		)
+	}
+	if opts.MaxConcurrentStreams > 0 {
+		serverOpts = append(serverOpts, _grpc.MaxConcurrentStreams(opts.MaxConcurrentStreams))
+	}
+	if opts.MaxRecvMsgSize > 0 {
+		serverOpts = append(serverOpts, _grpc.MaxRecvMsgSize(opts.MaxRecvMsgSize))
+	}
+	if opts.MaxSendMsgSize > 0 {
+		serverOpts = append(serverOpts, _grpc.MaxSendMsgSize(opts.MaxSendMsgSize))
 	}
+	if opts.TLS != nil {
+		config, err := g.tlsConfig(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		serverOpts = append(serverOpts, _grpc.Creds(_credentials.NewTLS(config)))
+	}
+	g.srv = _grpc.NewServer(serverOpts...)
+	if !opts.DisableHealthCheck {
+		g.health = _health.NewServer()
+		_healthpb.RegisterHealthServer(g.srv, g.health)
+	}
+	if !opts.DisableReflection {
+		_reflection.Register(g.srv)
+	}
+	return g, nil
+}
+
+func (g *Server) tlsConfig(opts *TLSOpts) (*tls.Config, error) {
+	reloader, err := _certs.NewReloader(&_certs.Opts{
+		CertFile:       opts.CertFile,
+		KeyFile:        opts.KeyFile,
+		CAFiles:        opts.ClientCAFiles,
+		ReloadInterval: opts.ReloadInterval,
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.certs = reloader
+	clientAuth := tls.RequireAndVerifyClientCert
+	if opts.ClientCertOptional {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	config := reloader.ServerConfig(clientAuth)
+	if opts.MinVersion > 0 {
+		config.MinVersion = opts.MinVersion
+	}
+	// set here rather than left to grpc credentials, which add h2 to their own copy only,
+	// while config returned for client by reloader is a copy of this one.
+	config.NextProtos = []string{"h2"}
+	return config, nil
 }
 
 func (g *Server) Server() *_grpc.Server {
 	return g.srv
 }
 
-func (g *Server) Run() {
-	_reflection.Register(g.srv)
-	lis, err := _reuseport.Listen("tcp4", fmt.Sprintf(":%d", g.port))
+// MaxRecvMsgSize return limit of received message size in bytes.
+func (g *Server) MaxRecvMsgSize() int {
+	return g.maxRecvMsgSize
+}
+
+// Run listen and serve until ctx is done or Shutdown is called. Blocking, execute it inside goroutine.
+// Once ctx is done, in-flight calls are given ShutdownTimeout to finish. Return nil if server is stopped,
+// otherwise the listen or serve error, which is sent to ListenError as well.
+func (g *Server) Run(ctx context.Context) error {
+	lis, err := g.listen()
 	if err != nil {
-		g.errChan <- err
+		g.fail(err)
+		return err
+	}
+	g.mu.Lock()
+	g.lis = lis
+	g.mu.Unlock()
+
+	served := make(chan struct{})
+	defer close(served)
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), g.shutdownTimeout)
+			defer cancel()
+			g.Shutdown(shutdownCtx)
+		case <-served:
+		}
+	}()
+
+	if err := g.srv.Serve(lis); err != nil && err != _grpc.ErrServerStopped {
+		g.fail(err)
+		return err
 	}
-	g.errChan <- g.srv.Serve(lis)
+	return nil
+}
+
+func (g *Server) listen() (net.Listener, error) {
+	switch {
+	case g.listener != nil:
+		return g.listener, nil
+	case g.unixSocket != "":
+		if info, err := os.Stat(g.unixSocket); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return nil, fmt.Errorf("grpcserver: %s exists and is not a socket", g.unixSocket)
+			}
+			if err := os.Remove(g.unixSocket); err != nil {
+				return nil, err
+			}
+		}
+		return net.Listen("unix", g.unixSocket)
+	}
+	return _reuseport.Listen("tcp4", fmt.Sprintf(":%d", g.port))
+}
+
+// fail send err to ListenError without blocking if nobody listens.
+func (g *Server) fail(err error) {
+	select {
+	case g.errChan <- err:
+	default:
+	}
+}
+
+// Addr return address listened on, nil if server is not running.
+func (g *Server) Addr() net.Addr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.lis == nil {
+		return nil
+	}
+	return g.lis.Addr()
+}
+
+// Shutdown report not serving through health service, stop accepting calls and wait for in-flight ones to finish.
+// Once ctx is done, they are cancelled and connections are closed, ctx error is returned.
+// Open health watches are sent NOT_SERVING and ended right away, so they don't hold shutdown.
+func (g *Server) Shutdown(ctx context.Context) error {
+	g.notServing()
+	defer func() {
+		if g.certs != nil {
+			g.certs.Close()
+		}
+	}()
+	stopped := make(chan struct{})
+	go func() {
+		g.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		g.srv.Stop()
+		<-stopped
+		return ctx.Err()
+	}
+}
+
+// Close stop server immediately, in-flight calls are cancelled and connections are closed.
+func (g *Server) Close() {
+	g.notServing()
+	g.srv.Stop()
+	if g.certs != nil {
+		g.certs.Close()
+	}
+}
+
+// ServeHTTP serve call received by other HTTP/2 server, e.g. sharing port with HTTP, see package mux.
+// TLS, Keepalive and MaxConcurrentStreams options don't apply, they are up to that server.
+// Use Close rather than Shutdown or Stop to stop server serving this way.
+func (g *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.srv.ServeHTTP(w, r)
 }
 
+// Stop stop server gracefully, waiting for in-flight calls up to ShutdownTimeout before they are cancelled.
 func (g *Server) Stop() {
-	g.srv.GracefulStop()
+	ctx, cancel := context.WithTimeout(context.Background(), g.shutdownTimeout)
+	defer cancel()
+	g.Shutdown(ctx)
 }
 
+// ListenError return channel receiving listen or serve error of Run.
 func (g *Server) ListenError() <-chan error {
 	return g.errChan
 }
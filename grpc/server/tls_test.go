@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_certs "github.com/mfathirirhas/godevkit/certs"
+)
+
+func TestMTLSNegotiatesH2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := _certs.WriteSelfSigned(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := New(&Opts{
+		TLS: &TLSOpts{CertFile: certFile, KeyFile: keyFile, ClientCAFiles: []string{certFile}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.Run(ctx)
+	var addr string
+	for i := 0; i < 100 && addr == ""; i++ {
+		if a := g.Addr(); a != nil {
+			addr = a.String()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addr == "" {
+		t.Fatal("server is not listening")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pem, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem)
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		ServerName:   "localhost",
+		NextProtos:   []string{"h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if p := conn.ConnectionState().NegotiatedProtocol; p != "h2" {
+		t.Fatalf("negotiated protocol %q, want h2", p)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	_trace "github.com/mfathirirhas/godevkit/trace"
+	_grpc "google.golang.org/grpc"
+	_metadata "google.golang.org/grpc/metadata"
+	_status "google.golang.org/grpc/status"
+)
+
+// metadataCarrier trace.Carrier of grpc metadata.
+type metadataCarrier _metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := _metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	_metadata.MD(c).Set(key, value)
+}
+
+// unaryTrace continue trace of incoming traceparent metadata with server span of each call.
+func (g *Server) unaryTrace(ctx context.Context, req interface{}, info *_grpc.UnaryServerInfo, handler _grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := g.startSpan(ctx, info.FullMethod)
+	defer span.End()
+	resp, err := handler(ctx, req)
+	endSpan(span, err)
+	return resp, err
+}
+
+// streamTrace continue trace of incoming traceparent metadata with server span of each stream.
+func (g *Server) streamTrace(srv interface{}, ss _grpc.ServerStream, info *_grpc.StreamServerInfo, handler _grpc.StreamHandler) error {
+	ctx, span := g.startSpan(ss.Context(), info.FullMethod)
+	defer span.End()
+	err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	endSpan(span, err)
+	return err
+}
+
+func (g *Server) startSpan(ctx context.Context, fullMethod string) (context.Context, *_trace.Span) {
+	tracer := g.tracer
+	if tracer == nil {
+		tracer = _trace.Default()
+	}
+	if md, ok := _metadata.FromIncomingContext(ctx); ok {
+		ctx = _trace.ContextWithRemote(ctx, _trace.Extract(metadataCarrier(md)))
+	}
+	name := strings.TrimPrefix(fullMethod, "/")
+	ctx, span := tracer.Start(ctx, name, _trace.SpanKindServer)
+	span.SetAttribute("rpc.system", "grpc")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		span.SetAttribute("rpc.service", name[:i])
+		span.SetAttribute("rpc.method", name[i+1:])
+	}
+	return ctx, span
+}
+
+func endSpan(span *_trace.Span, err error) {
+	code := _status.Code(err)
+	span.SetAttribute("rpc.grpc.status_code", int(code))
+	if err != nil {
+		span.SetStatus(_trace.StatusError, code.String()+": "+_status.Convert(err).Message())
+	}
+}
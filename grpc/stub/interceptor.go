@@ -0,0 +1,45 @@
+package stub
+
+import (
+	"context"
+
+	_uuid "github.com/google/uuid"
+	_grpc "google.golang.org/grpc"
+	_metadata "google.golang.org/grpc/metadata"
+)
+
+const (
+	metadataRequestID  = "request-id"
+	metadataXRequestID = "x-request-id"
+)
+
+// withRequestID send request-id metadata if not set already. It's x-request-id if set,
+// or the one of incoming call if stub is called by grpc handler, otherwise generated.
+func withRequestID(ctx context.Context) context.Context {
+	id := ""
+	if md, ok := _metadata.FromOutgoingContext(ctx); ok {
+		if len(md.Get(metadataRequestID)) > 0 {
+			return ctx
+		}
+		if v := md.Get(metadataXRequestID); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if md, ok := _metadata.FromIncomingContext(ctx); ok && id == "" {
+		if v := md.Get(metadataRequestID); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = _uuid.New().String()
+	}
+	return _metadata.AppendToOutgoingContext(ctx, metadataRequestID, id)
+}
+
+func unaryRequestID(ctx context.Context, method string, req, reply interface{}, cc *_grpc.ClientConn, invoker _grpc.UnaryInvoker, opts ..._grpc.CallOption) error {
+	return invoker(withRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+func streamRequestID(ctx context.Context, desc *_grpc.StreamDesc, cc *_grpc.ClientConn, method string, streamer _grpc.Streamer, opts ..._grpc.CallOption) (_grpc.ClientStream, error) {
+	return streamer(withRequestID(ctx), desc, cc, method, opts...)
+}
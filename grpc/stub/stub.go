@@ -3,10 +3,15 @@ package stub
 import (
 	"context"
 	"log"
+	"net"
+	"strings"
 	"time"
 
+	_certs "github.com/mfathirirhas/godevkit/certs"
+	_trace "github.com/mfathirirhas/godevkit/trace"
 	_grpc "google.golang.org/grpc"
 	_conn "google.golang.org/grpc/connectivity"
+	_credentials "google.golang.org/grpc/credentials"
 )
 
 const (
@@ -15,15 +20,19 @@ const (
 
 type Stub struct {
 	client *_grpc.ClientConn
+	certs  *_certs.Reloader
 }
 
 type Options struct {
 	// Address of grpc server, i.e. 10.10.10.10:234434
 	Address string
 
-	// IsTLSEnabled if true then authentication to server needed with cert.
+	// IsTLSEnabled if true then connection is secured with TLS, server certificate is verified with system CAs unless TLS is set.
 	IsTLSEnabled bool
 
+	// TLS optional, if set then TLS is enabled with its root CAs, client certificate for mTLS and server name.
+	TLS *TLSOpts
+
 	// WaitConnectionReady wait till connection ready. Blocking.
 	// If true then will block for as long as the ConnectionTimeOut.
 	WaitConnectionReady bool
@@ -36,6 +45,29 @@ type Options struct {
 	// if WaitConnectionReady is false then will be used as time to wait in WaitForStateChange while retrying.
 	// Default is 5 seconds
 	ConnectionTimeOut int
+
+	// Tracer optional, each call is traced by client span whose context is sent in traceparent metadata,
+	// as child of span in call context. Default is trace.Default().
+	Tracer *_trace.Tracer
+}
+
+// TLSOpts TLS options of stub. Files are PEM and reloaded once they change on disk, see certs.WriteSelfSigned for local testing.
+type TLSOpts struct {
+	// RootCAFiles optional, CAs to verify server certificate with. Default is system CAs.
+	RootCAFiles []string
+
+	// CertFile and KeyFile optional, client certificate for mTLS.
+	CertFile string
+	KeyFile  string
+
+	// ServerName optional, name verified in server certificate, IP is matched against IP SANs. Default is host of Address.
+	ServerName string
+
+	// MinVersion minimum TLS version, e.g. tls.VersionTLS13. Default is TLS 1.2.
+	MinVersion uint16
+
+	// ReloadInterval how often files are checked for changes. Default is 1 minute, negative disables reload.
+	ReloadInterval time.Duration
 }
 
 func New(o *Options) (*Stub, error) {
@@ -47,25 +79,56 @@ func New(o *Options) (*Stub, error) {
 
 	// setting dial options
 	dialOpts := []_grpc.DialOption{}
-	if o.IsTLSEnabled {
-		// TODO add tls support
+	var reloader *_certs.Reloader
+	if o.IsTLSEnabled || o.TLS != nil {
+		tlsOpts := o.TLS
+		if tlsOpts == nil {
+			tlsOpts = &TLSOpts{}
+		}
+		var err error
+		reloader, err = _certs.NewReloader(&_certs.Opts{
+			CertFile:       tlsOpts.CertFile,
+			KeyFile:        tlsOpts.KeyFile,
+			CAFiles:        tlsOpts.RootCAFiles,
+			ReloadInterval: tlsOpts.ReloadInterval,
+		})
+		if err != nil {
+			return nil, err
+		}
+		serverName := tlsOpts.ServerName
+		if serverName == "" {
+			serverName = addressHost(o.Address)
+		}
+		config := reloader.ClientConfig(serverName)
+		if tlsOpts.MinVersion > 0 {
+			config.MinVersion = tlsOpts.MinVersion
+		}
+		dialOpts = append(dialOpts, _grpc.WithTransportCredentials(_credentials.NewTLS(config)))
 	} else {
 		dialOpts = append(dialOpts, _grpc.WithInsecure())
 	}
 	if o.WaitConnectionReady {
 		dialOpts = append(dialOpts, _grpc.WithBlock())
 	}
+	dialOpts = append(dialOpts,
+		_grpc.WithChainUnaryInterceptor(unaryRequestID, unaryTrace(o.Tracer)),
+		_grpc.WithChainStreamInterceptor(streamRequestID, streamTrace(o.Tracer)),
+	)
 
 	conn, err := _grpc.DialContext(ctx, o.Address, dialOpts...)
 	if err != nil {
 		if conn != nil {
 			defer conn.Close()
 		}
+		if reloader != nil {
+			reloader.Close()
+		}
 		return nil, err
 	}
 
 	stub := &Stub{
 		client: conn,
+		certs:  reloader,
 	}
 
 	retryCount := 0
@@ -118,5 +181,20 @@ func (s *Stub) ServerAddress() string {
 }
 
 func (s *Stub) Close() error {
+	if s.certs != nil {
+		s.certs.Close()
+	}
 	return s.client.Close()
 }
+
+// addressHost return host of dial address, e.g. example.com of dns:///example.com:443.
+func addressHost(address string) string {
+	if i := strings.LastIndex(address, "/"); i >= 0 {
+		address = address[i+1:]
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
@@ -0,0 +1,97 @@
+package stub
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	_trace "github.com/mfathirirhas/godevkit/trace"
+	_grpc "google.golang.org/grpc"
+	_metadata "google.golang.org/grpc/metadata"
+	_status "google.golang.org/grpc/status"
+)
+
+// unaryTrace trace each call with client span and send its context in traceparent metadata.
+func unaryTrace(tracer *_trace.Tracer) _grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *_grpc.ClientConn, invoker _grpc.UnaryInvoker, opts ..._grpc.CallOption) error {
+		ctx, span := startSpan(ctx, tracer, method)
+		defer span.End()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// streamTrace trace each stream with client span and send its context in traceparent metadata.
+// Span ends once stream is finished, i.e. RecvMsg returns error or io.EOF, or the only response of
+// client streaming call is received.
+func streamTrace(tracer *_trace.Tracer) _grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *_grpc.StreamDesc, cc *_grpc.ClientConn, method string, streamer _grpc.Streamer, opts ..._grpc.CallOption) (_grpc.ClientStream, error) {
+		ctx, span := startSpan(ctx, tracer, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endSpan(span, err)
+			span.End()
+			return nil, err
+		}
+		return &tracedStream{ClientStream: cs, desc: desc, span: span}, nil
+	}
+}
+
+func startSpan(ctx context.Context, tracer *_trace.Tracer, method string) (context.Context, *_trace.Span) {
+	if tracer == nil {
+		tracer = _trace.Default()
+	}
+	name := strings.TrimPrefix(method, "/")
+	ctx, span := tracer.Start(ctx, name, _trace.SpanKindClient)
+	span.SetAttribute("rpc.system", "grpc")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		span.SetAttribute("rpc.service", name[:i])
+		span.SetAttribute("rpc.method", name[i+1:])
+	}
+	// replace trace context already in outgoing metadata, e.g. of the gateway, rather than appending to it,
+	// server would take the first value which is not the parent of the call.
+	md, _ := _metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	sc := span.SpanContext()
+	md.Set(_trace.HeaderTraceparent, sc.Traceparent())
+	if sc.TraceState != "" {
+		md.Set(_trace.HeaderTracestate, sc.TraceState)
+	} else {
+		delete(md, _trace.HeaderTracestate)
+	}
+	return _metadata.NewOutgoingContext(ctx, md), span
+}
+
+func endSpan(span *_trace.Span, err error) {
+	code := _status.Code(err)
+	span.SetAttribute("rpc.grpc.status_code", int(code))
+	if err != nil {
+		span.SetStatus(_trace.StatusError, code.String()+": "+_status.Convert(err).Message())
+	}
+}
+
+// tracedStream client stream ending its span once it's finished.
+type tracedStream struct {
+	_grpc.ClientStream
+	desc *_grpc.StreamDesc
+	span *_trace.Span
+	once sync.Once
+}
+
+func (s *tracedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	// client streaming call is finished by its only response, e.g. CloseAndRecv, without io.EOF.
+	if err != nil || !s.desc.ServerStreams {
+		s.once.Do(func() {
+			if err == io.EOF {
+				endSpan(s.span, nil)
+			} else {
+				endSpan(s.span, err)
+			}
+			s.span.End()
+		})
+	}
+	return err
+}
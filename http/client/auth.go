@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenRefreshBefore = time.Minute
+	defaultTokenTimeout       = 30 * time.Second
+)
+
+var (
+	ErrTokenEmpty = errors.New("httpclient: token endpoint returned empty access token")
+)
+
+// Auth set credentials of outgoing request, e.g. Authorization header.
+type Auth interface {
+	Authorize(req *http.Request) error
+}
+
+// TokenRefresher optionally implemented by Auth which credentials can be refreshed.
+// Request rejected with 401 is retried once after Refresh, if its body can be replayed.
+type TokenRefresher interface {
+	// Refresh force refreshing credentials used by req.
+	Refresh(req *http.Request) error
+}
+
+// TokenStore shared token cache, so all replicas share tokens, e.g. *redis.Redis.
+type TokenStore interface {
+	Get(key string) string
+	Setex(key string, value interface{}, seconds int64) error
+}
+
+type headerAuth struct {
+	name  string
+	value string
+}
+
+func (h *headerAuth) Authorize(req *http.Request) error {
+	req.Header.Set(h.name, h.value)
+	return nil
+}
+
+// BearerToken static bearer token auth.
+func BearerToken(token string) Auth {
+	return &headerAuth{name: "Authorization", value: "Bearer " + token}
+}
+
+// BasicAuth http basic auth.
+func BasicAuth(username string, password string) Auth {
+	return &headerAuth{name: "Authorization", value: "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))}
+}
+
+// HeaderAuth custom header auth scheme, e.g. HeaderAuth("X-Api-Key", key).
+func HeaderAuth(name string, value string) Auth {
+	return &headerAuth{name: name, value: value}
+}
+
+// ClientCredentials OAuth2 client credentials grant auth.
+// Tokens are cached until they're about to expire, then refreshed before used.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// EndpointParams optional, additional params sent to token endpoint, e.g. audience.
+	EndpointParams url.Values
+
+	// SecretInParams if true then client id and secret are sent in request body instead of basic auth header.
+	SecretInParams bool
+
+	// RefreshBefore refresh token this long before it expires. Default is 1 minute, or half of token lifetime if shorter.
+	RefreshBefore time.Duration
+
+	// Store optional, if set then tokens are shared through it, e.g. *redis.Redis.
+	Store TokenStore
+
+	// HTTPClient optional, client to call token endpoint. Default has 30 seconds timeout.
+	HTTPClient *http.Client
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// Token OAuth2 access token.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+	RefreshAt   time.Time `json:"refresh_at"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (t *Token) header() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+func (t *Token) valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && now.Before(t.RefreshAt)
+}
+
+func (c *ClientCredentials) Authorize(req *http.Request) error {
+	t, err := c.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", t.header())
+	return nil
+}
+
+// Token return cached token, or fetch new one if it's about to expire.
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.token.valid(now) {
+		return c.token, nil
+	}
+	if t := c.load(); t.valid(now) {
+		c.token = t
+		return t, nil
+	}
+	return c.fetch(ctx)
+}
+
+// Refresh fetch new token, unless the token used by req has been replaced already.
+func (c *ClientCredentials) Refresh(req *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != nil && req.Header.Get("Authorization") != c.token.header() {
+		return nil
+	}
+	_, err := c.fetch(req.Context())
+	return err
+}
+
+func (c *ClientCredentials) storeKey() string {
+	return fmt.Sprintf("httpclient:oauth2:%s:%s:%s", c.TokenURL, c.ClientID, strings.Join(c.Scopes, " "))
+}
+
+func (c *ClientCredentials) load() *Token {
+	if c.Store == nil {
+		return nil
+	}
+	v := c.Store.Get(c.storeKey())
+	if v == "" {
+		return nil
+	}
+	var t Token
+	if err := json.Unmarshal([]byte(v), &t); err != nil {
+		return nil
+	}
+	return &t
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (*Token, error) {
+	params := url.Values{}
+	for k, v := range c.EndpointParams {
+		params[k] = append(params[k], v...)
+	}
+	params.Set("grant_type", "client_credentials")
+	if len(c.Scopes) > 0 {
+		params.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.SecretInParams {
+		params.Set("client_id", c.ClientID)
+		params.Set("client_secret", c.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mimeFormURLEncoded)
+	req.Header.Set("Accept", "application/json")
+	if !c.SecretInParams {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: defaultTokenTimeout}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("httpclient: token endpoint returned %s: %s", resp.Status, body)
+	}
+	var tr tokenResponse
+	if err = json.Unmarshal(body, &tr); err != nil {
+		return nil, err
+	}
+	if tr.AccessToken == "" {
+		return nil, ErrTokenEmpty
+	}
+
+	now := time.Now()
+	t := &Token{
+		AccessToken: tr.AccessToken,
+		TokenType:   tr.TokenType,
+	}
+	if tr.ExpiresIn > 0 {
+		lifetime := time.Duration(tr.ExpiresIn) * time.Second
+		refreshBefore := c.RefreshBefore
+		if refreshBefore <= 0 {
+			refreshBefore = defaultTokenRefreshBefore
+		}
+		if refreshBefore > lifetime/2 {
+			refreshBefore = lifetime / 2
+		}
+		t.Expiry = now.Add(lifetime)
+		t.RefreshAt = t.Expiry.Add(-refreshBefore)
+	} else {
+		// no expiry given, keep it until rejected.
+		t.RefreshAt = now.Add(100 * 365 * 24 * time.Hour)
+	}
+	c.token = t
+
+	if ttl := int64(time.Until(t.RefreshAt) / time.Second); c.Store != nil && tr.ExpiresIn > 0 && ttl > 0 {
+		if b, err := json.Marshal(t); err == nil {
+			c.Store.Setex(c.storeKey(), string(b), ttl)
+		}
+	}
+	return t, nil
+}
+
+// authorize set credentials on request, retry once on 401 after refreshing credentials if auth supports it.
+func authorize(auth Auth) Interceptor {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		authReq := req.Clone(req.Context())
+		if err := auth.Authorize(authReq); err != nil {
+			return nil, err
+		}
+		resp, err := next(authReq)
+		refresher, ok := auth.(TokenRefresher)
+		if err != nil || !ok || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if !replayable || refresher.Refresh(authReq) != nil {
+			return resp, err
+		}
+		drain(resp)
+
+		retryReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retryReq.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if err = auth.Authorize(retryReq); err != nil {
+			return nil, err
+		}
+		return next(retryReq)
+	}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchConcurrency = 10
+)
+
+// BatchRequest request of Batch.
+type BatchRequest struct {
+	// Method default is GET. Body of POST, PUT and PATCH is encoded as Request.ContentType, DELETE as form.
+	Method  string
+	Request *Request
+}
+
+// BatchOpts options of Batch.
+type BatchOpts struct {
+	// Concurrency maximum requests sent at once. Default is 10.
+	Concurrency int
+
+	// FailFast if true then the rest of requests are cancelled after the first failure, both in-flight ones
+	// and ones not sent yet. Otherwise all requests are sent and their failures collected.
+	FailFast bool
+
+	// RequestTimeout optional, timeout of each request including retries.
+	RequestTimeout time.Duration
+
+	// Timeout optional, deadline of the whole batch.
+	Timeout time.Duration
+
+	// IsFailure optional, default is response error or status code >= 400.
+	IsFailure func(*Response) bool
+}
+
+// BatchError failures of Batch requests.
+type BatchError struct {
+	// Errors failure of requests by their index.
+	Errors map[int]error
+	// First index of the first failed request.
+	First int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("httpclient: %d batch requests failed, request %d: %v", len(e.Errors), e.First, e.Errors[e.First])
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Errors[e.First]
+}
+
+// Batch send requests concurrently and return their responses in the same order.
+// Error is *BatchError if any request failed. With FailFast, requests cancelled after the first failure have context.Canceled error.
+func (c *Client) Batch(ctx context.Context, opts *BatchOpts, reqs ...BatchRequest) ([]*Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts == nil {
+		opts = &BatchOpts{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	isFailure := opts.IsFailure
+	if isFailure == nil {
+		isFailure = defaultBatchFailure
+	}
+	var cancel context.CancelFunc
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		responses = make([]*Response, len(reqs))
+		batchErr  = &BatchError{Errors: make(map[int]error), First: -1}
+		sem       = make(chan struct{}, concurrency)
+	)
+	fail := func(i int, err error) {
+		mu.Lock()
+		batchErr.Errors[i] = err
+		if batchErr.First < 0 {
+			batchErr.First = i
+			if opts.FailFast {
+				cancel()
+			}
+		}
+		mu.Unlock()
+	}
+
+	for i := range reqs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			responses[i] = &Response{Error: err}
+			fail(i, err)
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			reqCtx := ctx
+			if opts.RequestTimeout > 0 {
+				var reqCancel context.CancelFunc
+				reqCtx, reqCancel = context.WithTimeout(ctx, opts.RequestTimeout)
+				defer reqCancel()
+			}
+			resp := c.method(reqCtx, reqs[i].Method, reqs[i].Request)
+			responses[i] = resp
+			if isFailure(resp) {
+				err := resp.Error
+				if err == nil {
+					err = fmt.Errorf("%w: %s", ErrUnexpectedStatus, resp.Status)
+				}
+				fail(i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if batchErr.First >= 0 {
+		return responses, batchErr
+	}
+	return responses, nil
+}
+
+// method send req with method, encoding body the same way as Client method of it.
+func (c *Client) method(ctx context.Context, method string, req *Request) *Response {
+	if req == nil {
+		return &Response{Error: ErrRequestNil}
+	}
+	switch method {
+	case "", http.MethodGet:
+		return c.Get(ctx, req)
+	case http.MethodHead:
+		return c.Head(ctx, req)
+	case http.MethodOptions:
+		return c.Options(ctx, req)
+	case http.MethodPost:
+		return c.Post(ctx, req)
+	case http.MethodPut:
+		return c.Put(ctx, req)
+	case http.MethodPatch:
+		return c.Patch(ctx, req)
+	case http.MethodDelete:
+		return c.Delete(ctx, req)
+	}
+	var body io.Reader
+	if req.Body != nil {
+		b, err := req.Encode(req.ContentType)
+		if err != nil {
+			return &Response{Error: err}
+		}
+		body = b
+	}
+	return c.call(ctx, method, req, body)
+}
+
+func defaultBatchFailure(r *Response) bool {
+	return r.Error != nil || r.StatusCode >= http.StatusBadRequest
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	_codec "github.com/mfathirirhas/godevkit/http/codec"
+)
+
+const (
+	mimeFormURLEncoded = "application/x-www-form-urlencoded"
+	mimeMultipartForm  = "multipart/form-data"
+)
+
+var (
+	ErrFormBody = errors.New("httpclient: form body must be map or struct")
+
+	fileType  = reflect.TypeOf(File{})
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// RegisterCodec register custom codec used by Request.Encode and Response.Scan, along with its content type aliases.
+func RegisterCodec(c _codec.Codec, aliases ...string) {
+	_codec.Register(c, aliases...)
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(contentType)
+	}
+	return mt
+}
+
+// formValues flatten body into form values and files.
+// Body can be url.Values, map with string key, or struct with `form` tags, fallback to `json` tags.
+// Struct fields of type File, *File or []File are returned as files, named by the tag if File.FieldName is empty.
+func formValues(body interface{}) (url.Values, []File, error) {
+	values := make(url.Values)
+	if body == nil {
+		return values, nil, nil
+	}
+	if uv, ok := body.(url.Values); ok {
+		for k, v := range uv {
+			values[k] = append(values[k], v...)
+		}
+		return values, nil, nil
+	}
+
+	v := reflect.ValueOf(body)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return values, nil, nil
+		}
+		v = v.Elem()
+	}
+	var files []File
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, nil, ErrFormBody
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			files = addFormValue(values, files, iter.Key().String(), iter.Value())
+		}
+	case reflect.Struct:
+		files = addFormStruct(values, files, v)
+	default:
+		return nil, nil, ErrFormBody
+	}
+	return values, files, nil
+}
+
+func addFormStruct(values url.Values, files []File, v reflect.Value) []File {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if field.PkgPath != "" && !field.Anonymous { // unexported
+			continue
+		}
+		name, omitEmpty := formTag(field)
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				files = addFormStruct(values, files, fv)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		files = addFormValue(values, files, name, fv)
+	}
+	return files
+}
+
+func formTag(field reflect.StructField) (name string, omitEmpty bool) {
+	tag, ok := field.Tag.Lookup("form")
+	if !ok {
+		tag = field.Tag.Get("json")
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty
+}
+
+func addFormValue(values url.Values, files []File, name string, v reflect.Value) []File {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return files
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == fileType && v.CanInterface():
+		f := v.Interface().(File)
+		if f.FieldName == "" {
+			f.FieldName = name
+		}
+		return append(files, f)
+	case v.Type() == timeType && v.CanInterface():
+		values.Add(name, v.Interface().(time.Time).Format(time.RFC3339))
+	case v.Type() == bytesType:
+		values.Add(name, string(v.Bytes()))
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			files = addFormValue(values, files, name, v.Index(i))
+		}
+	case !v.CanInterface():
+		// promoted through unexported embedded struct, only basic kinds can be read.
+		if s, ok := formatKind(v); ok {
+			values.Add(name, s)
+		}
+	default:
+		values.Add(name, fmt.Sprintf("%v", v.Interface()))
+	}
+	return files
+}
+
+// formatKind format value of basic kind without calling Interface, which panics on read-only values.
+func formatKind(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true
+	}
+	return "", false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
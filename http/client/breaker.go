@@ -0,0 +1,318 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerWindow      = 10 * time.Second
+	defaultBreakerBuckets     = 10
+	defaultBreakerMinRequests = 10
+	defaultBreakerOpenTimeout = 30 * time.Second
+)
+
+var (
+	ErrCircuitOpen = errors.New("httpclient: circuit breaker is open")
+)
+
+// CircuitState state of circuit breaker of a host.
+type CircuitState int
+
+const (
+	// CircuitClosed requests pass through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen requests fail fast with CircuitOpenError.
+	CircuitOpen
+	// CircuitHalfOpen limited trial requests pass through to check whether host has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitOpenError returned without sending request while circuit of the host is open.
+// errors.Is(err, ErrCircuitOpen) reports true for it.
+type CircuitOpenError struct {
+	Host string
+	// Until time the circuit turns half-open.
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("httpclient: circuit breaker is open for %s until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreaker options of per host circuit breaker.
+// Circuit opens when either FailureRatio or ConsecutiveFailures threshold is reached, if both are zero then ConsecutiveFailures is 5.
+type CircuitBreaker struct {
+	// Window rolling window to calculate failure ratio, at least 10 nanoseconds. Default is 10 seconds.
+	Window time.Duration
+
+	// FailureRatio open circuit if ratio of failed requests within Window reaches this, e.g. 0.5. Zero means disabled.
+	FailureRatio float64
+
+	// MinRequests minimum requests within Window before FailureRatio is evaluated. Default is 10.
+	MinRequests int
+
+	// ConsecutiveFailures open circuit after this many failures in a row. Zero means disabled.
+	ConsecutiveFailures int
+
+	// OpenTimeout how long circuit stays open before turning half-open. Default is 30 seconds.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests trial requests allowed while half-open, circuit closes once all of them succeed. Default is 1.
+	HalfOpenRequests int
+
+	// IsFailure optional, default is any error or 5xx status code.
+	// Requests canceled by caller are not recorded, either as failure or success.
+	IsFailure func(*http.Response, error) bool
+
+	// OnStateChange optional, called on every state change of a host circuit, e.g. for logging or metrics.
+	OnStateChange func(host string, from CircuitState, to CircuitState)
+}
+
+type breaker struct {
+	opts CircuitBreaker
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type bucket struct {
+	start    time.Time
+	success  int
+	failures int
+}
+
+type stateChange struct {
+	from CircuitState
+	to   CircuitState
+}
+
+type circuit struct {
+	mu              sync.Mutex
+	changes         []stateChange
+	state           CircuitState
+	openedAt        time.Time
+	consecutive     int
+	halfOpenPending int
+	halfOpenSuccess int
+	buckets         []bucket
+}
+
+func newBreaker(cb *CircuitBreaker) *breaker {
+	opts := *cb
+	if opts.Window <= 0 {
+		opts.Window = defaultBreakerWindow
+	}
+	// window is split into buckets of at least a nanosecond.
+	if opts.Window < defaultBreakerBuckets {
+		opts.Window = defaultBreakerBuckets
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = defaultBreakerMinRequests
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+	if opts.FailureRatio <= 0 && opts.ConsecutiveFailures <= 0 {
+		opts.ConsecutiveFailures = 5
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = defaultIsFailure
+	}
+	return &breaker{
+		opts:     opts,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+func defaultIsFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
+
+func (b *breaker) circuit(host string) *circuit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{buckets: make([]bucket, defaultBreakerBuckets)}
+		b.circuits[host] = c
+	}
+	return c
+}
+
+// unlock release circuit lock then fire state change callbacks, so callbacks can't deadlock the circuit.
+func (b *breaker) unlock(host string, c *circuit) {
+	changes := c.changes
+	c.changes = nil
+	c.mu.Unlock()
+	if b.opts.OnStateChange == nil {
+		return
+	}
+	for _, ch := range changes {
+		b.opts.OnStateChange(host, ch.from, ch.to)
+	}
+}
+
+func (b *breaker) intercept(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+	host := req.URL.Host
+	c := b.circuit(host)
+	trial, err := b.allow(host, c)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := next(req)
+	if errors.Is(err, context.Canceled) {
+		// canceled by caller, it tells nothing about host.
+		b.release(host, c, trial)
+		return resp, err
+	}
+	b.record(host, c, trial, b.opts.IsFailure(resp, err))
+	return resp, err
+}
+
+// release give back half-open trial slot without recording outcome.
+func (b *breaker) release(host string, c *circuit, trial bool) {
+	if !trial {
+		return
+	}
+	c.mu.Lock()
+	defer b.unlock(host, c)
+	if c.state == CircuitHalfOpen && c.halfOpenPending > 0 {
+		c.halfOpenPending--
+	}
+}
+
+// allow return error if circuit is open, trial is true if request is one of half-open trial requests.
+func (b *breaker) allow(host string, c *circuit) (trial bool, err error) {
+	c.mu.Lock()
+	defer b.unlock(host, c)
+	b.refresh(c, time.Now())
+	switch c.state {
+	case CircuitOpen:
+		return false, &CircuitOpenError{Host: host, Until: c.openedAt.Add(b.opts.OpenTimeout)}
+	case CircuitHalfOpen:
+		if c.halfOpenPending+c.halfOpenSuccess >= b.opts.HalfOpenRequests {
+			return false, &CircuitOpenError{Host: host, Until: time.Now()}
+		}
+		c.halfOpenPending++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b *breaker) record(host string, c *circuit, trial bool, failed bool) {
+	c.mu.Lock()
+	defer b.unlock(host, c)
+	now := time.Now()
+	if trial {
+		if c.state != CircuitHalfOpen {
+			return
+		}
+		c.halfOpenPending--
+		if failed {
+			b.open(c, now)
+			return
+		}
+		c.halfOpenSuccess++
+		if c.halfOpenSuccess >= b.opts.HalfOpenRequests {
+			b.setState(c, CircuitClosed)
+			c.consecutive = 0
+			for i := range c.buckets {
+				c.buckets[i] = bucket{}
+			}
+		}
+		return
+	}
+	if c.state != CircuitClosed {
+		return
+	}
+
+	bk := c.bucket(now, b.opts.Window)
+	if failed {
+		bk.failures++
+		c.consecutive++
+	} else {
+		bk.success++
+		c.consecutive = 0
+	}
+	if b.opts.ConsecutiveFailures > 0 && c.consecutive >= b.opts.ConsecutiveFailures {
+		b.open(c, now)
+		return
+	}
+	if b.opts.FailureRatio > 0 {
+		success, failures := c.counts(now, b.opts.Window)
+		total := success + failures
+		if total >= b.opts.MinRequests && float64(failures)/float64(total) >= b.opts.FailureRatio {
+			b.open(c, now)
+		}
+	}
+}
+
+// refresh turn open circuit into half-open once OpenTimeout passed.
+func (b *breaker) refresh(c *circuit, now time.Time) {
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= b.opts.OpenTimeout {
+		c.halfOpenPending = 0
+		c.halfOpenSuccess = 0
+		b.setState(c, CircuitHalfOpen)
+	}
+}
+
+func (b *breaker) open(c *circuit, now time.Time) {
+	c.openedAt = now
+	b.setState(c, CircuitOpen)
+}
+
+func (b *breaker) setState(c *circuit, state CircuitState) {
+	if c.state == state {
+		return
+	}
+	c.changes = append(c.changes, stateChange{from: c.state, to: state})
+	c.state = state
+}
+
+// bucket return bucket of current time, resetting the stale one.
+func (c *circuit) bucket(now time.Time, window time.Duration) *bucket {
+	size := window / time.Duration(len(c.buckets))
+	start := now.Truncate(size)
+	bk := &c.buckets[int(start.UnixNano()/int64(size))%len(c.buckets)]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+func (c *circuit) counts(now time.Time, window time.Duration) (success int, failures int) {
+	for _, bk := range c.buckets {
+		if now.Sub(bk.start) < window {
+			success += bk.success
+			failures += bk.failures
+		}
+	}
+	return
+}
@@ -0,0 +1,689 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_local "github.com/mfathirirhas/godevkit/cache/local"
+	_memcached "github.com/mfathirirhas/godevkit/cache/memcached"
+	_redis "github.com/mfathirirhas/godevkit/cache/redis"
+)
+
+const (
+	// HeaderCache set on responses passed through ResponseCache, value is one of Cache* constants.
+	HeaderCache = "X-Cache"
+
+	// CacheHit response served from cache without contacting server.
+	CacheHit = "HIT"
+	// CacheMiss response fetched from server.
+	CacheMiss = "MISS"
+	// CacheRevalidated cached response confirmed by server with 304.
+	CacheRevalidated = "REVALIDATED"
+	// CacheStale stale cached response served because server failed.
+	CacheStale = "STALE"
+
+	defaultCacheMaxBodySize = 1 << 20 // 1MB
+	defaultCacheKeepStale   = 24 * time.Hour
+	maxCacheHeuristic       = 24 * time.Hour
+	maxMemcachedTTL         = 30 * 24 * time.Hour
+	cacheKeyPrefix          = "httpclient:cache:"
+)
+
+// CacheStore storage of cached responses, see LocalCacheStore, RedisCacheStore and MemcachedCacheStore.
+type CacheStore interface {
+	// Get return value of key, false if it does not exist.
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Del(key string) error
+}
+
+// ResponseCache options of RFC 7234 cache of GET responses.
+// Cache-Control, Expires, Vary and validators (ETag, Last-Modified) of responses are respected,
+// as well as Cache-Control of requests. Identical concurrent GETs missing the cache are coalesced into one request,
+// if it fails then one of the others is sent instead. Responses which can't be stored, e.g. no-store, private
+// in Shared cache or larger than MaxBodySize, can't be shared either, so the others are sent on their own then.
+type ResponseCache struct {
+	Store CacheStore
+
+	// Shared if true then behave as shared cache, i.e. s-maxage is used and private responses are not stored,
+	// nor responses to requests with Authorization, including the one set by Opts.Auth, unless they're explicitly public.
+	// Default is private cache, suitable for a client acting on behalf of one user.
+	Shared bool
+
+	// StaleIfError serve stale response up to this long after it expired if server can't be reached or returns 5xx,
+	// unless response has must-revalidate. stale-if-error directive extends it.
+	StaleIfError time.Duration
+
+	// KeepStale keep expired responses with validators this long to be revalidated. Default is 24 hours.
+	KeepStale time.Duration
+
+	// MaxBodySize responses with larger body are not stored. Default is 1MB.
+	MaxBodySize int64
+}
+
+// CacheTransport wrap transport with cache, for http.Client not created by this package.
+func CacheTransport(rc *ResponseCache, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c := newHTTPCache(rc)
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return c.intercept(req, transport.RoundTrip)
+	})
+}
+
+type httpCache struct {
+	opts ResponseCache
+	// authorized requests get Authorization after cache, i.e. from Opts.Auth.
+	authorized bool
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight in-flight upstream request shared by identical requests.
+type flight struct {
+	done   chan struct{}
+	req    *http.Request
+	entry  *cacheEntry
+	status string
+	// failed leader got no response, e.g. its request is cancelled.
+	failed bool
+}
+
+type cacheEntry struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	RequestTime  time.Time   `json:"request_time"`
+	ResponseTime time.Time   `json:"response_time"`
+
+	// Vary set on entry stored by url only, pointing to entries stored by url and vary header values.
+	Vary []string `json:"vary,omitempty"`
+}
+
+func newHTTPCache(rc *ResponseCache) *httpCache {
+	opts := *rc
+	if opts.KeepStale <= 0 {
+		opts.KeepStale = defaultCacheKeepStale
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultCacheMaxBodySize
+	}
+	return &httpCache{
+		opts:    opts,
+		flights: make(map[string]*flight),
+	}
+}
+
+func (c *httpCache) intercept(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		resp, err := next(req)
+		if err == nil && isUnsafeMethod(req.Method) && resp.StatusCode < 400 {
+			c.invalidate(req.URL)
+		}
+		return resp, err
+	}
+	// partial and conditional requests made by caller are not handled by cache.
+	if req.Header.Get("Range") != "" || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return next(req)
+	}
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-store") {
+		return next(req)
+	}
+
+	now := time.Now()
+	key, entry := c.lookup(req)
+	if entry != nil && !reqCC.has("no-cache") && c.fresh(entry, reqCC, now) {
+		return entry.response(req, CacheHit, now), nil
+	}
+	if reqCC.has("only-if-cached") {
+		return gatewayTimeout(req), nil
+	}
+
+	for {
+		f, leader := c.join(key)
+		if leader {
+			resp, shared, err := c.fetch(req, entry, reqCC, next)
+			c.leave(key, f, req, shared, resp)
+			return resp, err
+		}
+		select {
+		case <-f.done:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if f.entry != nil && varyMatches(f.entry.Header, f.req, req) {
+			return f.entry.response(req, f.status, time.Now()), nil
+		}
+		if !f.failed {
+			// leader's response can't be shared, send our own.
+			resp, _, err := c.fetch(req, entry, reqCC, next)
+			return resp, err
+		}
+		// leader got no response, one of followers sends it again for the others.
+	}
+}
+
+// fetch send request upstream, revalidating entry if it has validators.
+// Return entry which response is made of, nil if response is streamed from server or must not be reused.
+func (c *httpCache) fetch(req *http.Request, entry *cacheEntry, reqCC cacheControl, next RoundTripFunc) (*http.Response, *cacheEntry, error) {
+	outReq := req
+	conditional := false
+	if entry != nil {
+		etag, lastModified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outReq = req.Clone(req.Context())
+			if etag != "" {
+				outReq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outReq.Header.Set("If-Modified-Since", lastModified)
+			}
+			conditional = true
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := next(outReq)
+	responseTime := time.Now()
+
+	if entry != nil && (err != nil || resp.StatusCode >= http.StatusInternalServerError) && c.staleIfError(entry, reqCC, responseTime) {
+		drain(resp)
+		return entry.response(req, CacheStale, responseTime), entry, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		drain(resp)
+		entry.update(resp.Header, requestTime, responseTime)
+		c.store(req, entry)
+		return entry.response(req, CacheRevalidated, responseTime), entry, nil
+	}
+
+	resp.Header.Set(HeaderCache, CacheMiss)
+	if !c.storable(req, reqCC, resp) {
+		return resp, nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+	if int64(len(body)) > c.opts.MaxBodySize {
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp, nil, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del(HeaderCache)
+	stored := &cacheEntry{
+		Status:       resp.StatusCode,
+		Header:       header,
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	c.store(req, stored)
+	return resp, stored, nil
+}
+
+// join return in-flight request of key, leader is true if caller has to send it.
+func (c *httpCache) join(key string) (f *flight, leader bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := c.flights[key]; ok {
+		return f, false
+	}
+	f = &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	return f, true
+}
+
+func (c *httpCache) leave(key string, f *flight, req *http.Request, entry *cacheEntry, resp *http.Response) {
+	c.mu.Lock()
+	delete(c.flights, key)
+	c.mu.Unlock()
+	f.req = req
+	f.entry = entry
+	if resp != nil {
+		f.status = resp.Header.Get(HeaderCache)
+	} else {
+		f.failed = true
+	}
+	close(f.done)
+}
+
+// lookup return key of request and its cached entry, nil if not cached.
+func (c *httpCache) lookup(req *http.Request) (string, *cacheEntry) {
+	key := cacheKey(req.URL)
+	entry := c.load(key)
+	if entry == nil || len(entry.Vary) == 0 {
+		return key, entry
+	}
+	key = variantKey(key, entry.Vary, req.Header)
+	return key, c.load(key)
+}
+
+func (c *httpCache) load(key string) *cacheEntry {
+	b, ok, err := c.opts.Store.Get(key)
+	if err != nil || !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// store save entry, entries of responses with Vary are stored per vary header values.
+func (c *httpCache) store(req *http.Request, entry *cacheEntry) {
+	ttl := c.lifetime(entry) - entry.age(entry.ResponseTime) + c.keep(entry)
+	if ttl <= 0 {
+		return
+	}
+	primary := cacheKey(req.URL)
+	if vary := varyNames(entry.Header); len(vary) > 0 {
+		if b, err := json.Marshal(&cacheEntry{Vary: vary}); err == nil {
+			c.opts.Store.Set(primary, b, ttl)
+		}
+		primary = variantKey(primary, vary, req.Header)
+	}
+	if b, err := json.Marshal(entry); err == nil {
+		c.opts.Store.Set(primary, b, ttl)
+	}
+}
+
+// invalidate cached entries of url after it's changed by unsafe method.
+func (c *httpCache) invalidate(u *url.URL) {
+	c.opts.Store.Del(cacheKey(u))
+}
+
+// storable report whether response can be stored, RFC 7234 section 3.
+func (c *httpCache) storable(req *http.Request, reqCC cacheControl, resp *http.Response) bool {
+	respCC := parseCacheControl(resp.Header)
+	if reqCC.has("no-store") || respCC.has("no-store") {
+		return false
+	}
+	if c.opts.Shared {
+		if respCC.has("private") {
+			return false
+		}
+		if (c.authorized || req.Header.Get("Authorization") != "") && !respCC.has("public") && !respCC.has("s-maxage") && !respCC.has("must-revalidate") {
+			return false
+		}
+	}
+	for _, v := range varyNames(resp.Header) {
+		if v == "*" {
+			return false
+		}
+	}
+	explicit := respCC.has("max-age") || (c.opts.Shared && respCC.has("s-maxage")) || resp.Header.Get("Expires") != "" || respCC.has("public")
+	if !cacheableStatus(resp.StatusCode) && !(explicit && (resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusTemporaryRedirect)) {
+		return false
+	}
+	return explicit || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// fresh report whether entry can be served without revalidation, RFC 7234 section 4.2.
+func (c *httpCache) fresh(entry *cacheEntry, reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(entry.Header)
+	if respCC.has("no-cache") || (len(entry.Header.Values("Cache-Control")) == 0 && strings.Contains(entry.Header.Get("Pragma"), "no-cache")) {
+		return false
+	}
+	lifetime := c.lifetime(entry)
+	age := entry.age(now)
+	if maxAge, ok := reqCC.duration("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.duration("min-fresh"); ok {
+		lifetime -= minFresh
+	}
+	if age < lifetime {
+		return true
+	}
+	if respCC.has("must-revalidate") || (c.opts.Shared && (respCC.has("proxy-revalidate") || respCC.has("s-maxage"))) {
+		return false
+	}
+	if v, ok := reqCC["max-stale"]; ok {
+		if v == "" {
+			return true
+		}
+		maxStale, ok := reqCC.duration("max-stale")
+		return ok && age-lifetime <= maxStale
+	}
+	return false
+}
+
+// staleIfError report whether entry can be served if server failed, RFC 5861.
+func (c *httpCache) staleIfError(entry *cacheEntry, reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(entry.Header)
+	if respCC.has("must-revalidate") || (c.opts.Shared && respCC.has("proxy-revalidate")) {
+		return false
+	}
+	allowed := c.opts.StaleIfError
+	if d, ok := respCC.duration("stale-if-error"); ok && d > allowed {
+		allowed = d
+	}
+	if d, ok := reqCC.duration("stale-if-error"); ok {
+		allowed = d
+	}
+	return entry.age(now)-c.lifetime(entry) <= allowed
+}
+
+// keep how long entry is kept after it expires.
+func (c *httpCache) keep(entry *cacheEntry) time.Duration {
+	keep := c.opts.StaleIfError
+	if d, ok := parseCacheControl(entry.Header).duration("stale-if-error"); ok && d > keep {
+		keep = d
+	}
+	if (entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != "") && c.opts.KeepStale > keep {
+		keep = c.opts.KeepStale
+	}
+	return keep
+}
+
+// lifetime freshness lifetime of entry, RFC 7234 section 4.2.1.
+func (c *httpCache) lifetime(entry *cacheEntry) time.Duration {
+	respCC := parseCacheControl(entry.Header)
+	if c.opts.Shared {
+		if d, ok := respCC.duration("s-maxage"); ok {
+			return d
+		}
+	}
+	if d, ok := respCC.duration("max-age"); ok {
+		return d
+	}
+	date := entry.date()
+	if v := entry.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+	if v := entry.Header.Get("Last-Modified"); v != "" && cacheableStatus(entry.Status) {
+		lastModified, err := http.ParseTime(v)
+		if err != nil || !date.After(lastModified) {
+			return 0
+		}
+		heuristic := date.Sub(lastModified) / 10
+		if heuristic > maxCacheHeuristic {
+			heuristic = maxCacheHeuristic
+		}
+		return heuristic
+	}
+	return 0
+}
+
+// age current age of entry, RFC 7234 section 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparent := e.ResponseTime.Sub(e.date())
+	if apparent < 0 {
+		apparent = 0
+	}
+	var ageValue time.Duration
+	if v, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && v > 0 {
+		ageValue = time.Duration(v) * time.Second
+	}
+	corrected := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	initial := apparent
+	if corrected > initial {
+		initial = corrected
+	}
+	return initial + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// update stored headers with the ones of 304 response, RFC 7234 section 4.3.4.
+func (e *cacheEntry) update(header http.Header, requestTime time.Time, responseTime time.Time) {
+	for k, v := range header {
+		switch k {
+		case "Content-Length", "Transfer-Encoding", "Connection", HeaderCache:
+			continue
+		}
+		e.Header[k] = v
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+func (e *cacheEntry) response(req *http.Request, status string, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set(HeaderCache, status)
+	if status != CacheMiss {
+		header.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+	}
+	if status == CacheStale {
+		header.Add("Warning", `111 - "Revalidation Failed"`)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout)),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{HeaderCache: []string{CacheMiss}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+func cacheKey(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+	return cacheKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+func variantKey(key string, vary []string, header http.Header) string {
+	h := sha256.New()
+	for _, name := range vary {
+		fmt.Fprintf(h, "%s:%s\n", name, strings.Join(header.Values(name), ","))
+	}
+	return key + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyMatches report whether response to req1 can be used for req2.
+func varyMatches(header http.Header, req1 *http.Request, req2 *http.Request) bool {
+	for _, name := range varyNames(header) {
+		if name == "*" || strings.Join(req1.Header.Values(name), ",") != strings.Join(req2.Header.Values(name), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, value := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, value = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type localCacheStore struct {
+	cache *_local.Cache
+}
+
+// LocalCacheStore in-memory CacheStore of this process.
+func LocalCacheStore(c *_local.Cache) CacheStore {
+	return &localCacheStore{cache: c}
+}
+
+func (s *localCacheStore) Get(key string) ([]byte, bool, error) {
+	b, ok := s.cache.Get(key).([]byte)
+	return b, ok, nil
+}
+
+func (s *localCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.cache.SetTTL(key, value, ttlSeconds(ttl))
+	return nil
+}
+
+func (s *localCacheStore) Del(key string) error {
+	s.cache.Del(key)
+	return nil
+}
+
+type redisCacheStore struct {
+	redis *_redis.Redis
+}
+
+// RedisCacheStore CacheStore shared by all replicas.
+func RedisCacheStore(r *_redis.Redis) CacheStore {
+	return &redisCacheStore{redis: r}
+}
+
+func (s *redisCacheStore) Get(key string) ([]byte, bool, error) {
+	v := s.redis.Get(key)
+	return []byte(v), v != "", nil
+}
+
+func (s *redisCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.redis.Setex(key, value, int64(ttlSeconds(ttl)))
+}
+
+func (s *redisCacheStore) Del(key string) error {
+	return s.redis.Del(key)
+}
+
+type memcachedCacheStore struct {
+	memcached *_memcached.Memcached
+}
+
+// MemcachedCacheStore CacheStore shared by all replicas. Memcached limits item size to 1MB by default.
+func MemcachedCacheStore(m *_memcached.Memcached) CacheStore {
+	return &memcachedCacheStore{memcached: m}
+}
+
+func (s *memcachedCacheStore) Get(key string) ([]byte, bool, error) {
+	b, err := s.memcached.Get(key)
+	if errors.Is(err, _memcached.ErrCacheMiss) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (s *memcachedCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	// memcached treats ttl longer than 30 days as unix timestamp.
+	if ttl > maxMemcachedTTL {
+		ttl = maxMemcachedTTL
+	}
+	return s.memcached.SetTTL(key, value, ttlSeconds(ttl))
+}
+
+func (s *memcachedCacheStore) Del(key string) error {
+	if err := s.memcached.Del(key); err != nil && !errors.Is(err, _memcached.ErrCacheMiss) {
+		return err
+	}
+	return nil
+}
+
+// ttlSeconds round ttl up to seconds, stores with seconds precision would expire entries too early otherwise.
+func ttlSeconds(ttl time.Duration) int {
+	return int((ttl + time.Second - 1) / time.Second)
+}
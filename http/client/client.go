@@ -3,7 +3,6 @@ package client
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -18,21 +17,30 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	_uuid "github.com/google/uuid"
+	_codec "github.com/mfathirirhas/godevkit/http/codec"
+	_signature "github.com/mfathirirhas/godevkit/http/signature"
+	_trace "github.com/mfathirirhas/godevkit/trace"
 )
 
 const (
 	defaultMinBackOff = 50 * time.Millisecond
 	defaultMaxBackOff = 8 * time.Second
+
+	// maxDrainBody maximum bytes read from discarded response body to reuse its connection.
+	maxDrainBody = 64 << 10
+
+	headerIdempotencyKey = "Idempotency-Key"
 )
 
 var (
 	ErrRequestNil    = errors.New("httpclient: request cannot be nil")
 	ErrRequestURLNil = errors.New("httpclient: request url cannot be empty")
 
+	ErrUnsupportedContentType = errors.New("httpclient: no codec registered for content type")
+
 	random = func(min int64, max int64) int64 {
 		return rand.New(rand.NewSource(int64(time.Now().Nanosecond()))).Int63n(max-min) + min
 	}
@@ -44,9 +52,6 @@ var (
 		tempSleep := (temp / 2) + float64(random(0, int64(temp/2)))
 		return int64(math.Min(float64(max), float64(random(min, int64(tempSleep*3)))))
 	}
-
-	once   sync.Once
-	client *Client
 )
 
 type Client struct {
@@ -54,29 +59,57 @@ type Client struct {
 }
 
 type retry struct {
-	rt         http.RoundTripper
-	nums       int
-	retry      func(*http.Response, error) bool
-	backOff    func(attempt int, minWait time.Duration, maxWait time.Duration) time.Duration
-	minBackOff time.Duration
-	maxBackOff time.Duration
+	nums           int
+	retry          func(*http.Response, error) bool
+	backOff        func(attempt int, minWait time.Duration, maxWait time.Duration) time.Duration
+	minBackOff     time.Duration
+	maxBackOff     time.Duration
+	idempotencyKey bool
 }
 
-type logRT struct {
+type logging struct {
 	logger *log.Logger
-	rt     http.RoundTripper
 }
 
 type Opts struct {
-	MaxIdleConns    int
+	// MaxIdleConns maximum idle connections across all hosts. Default is 100.
+	MaxIdleConns int
+	// IdleConnTimeout how long idle connection is kept. Default is 90 seconds.
 	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost default is 2, as http.Transport.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost maximum connections per host, including dialing and active ones. Zero means no limit.
+	MaxConnsPerHost int
+
+	// DialTimeout default is 30 seconds.
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout default is 10 seconds.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout how long to wait for response header after request is sent. Zero means no timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// TLS optional, custom root CAs, client certificates for mTLS, min TLS version and SNI.
+	TLS *TLSOpts
+
+	// Proxy optional, proxy url with http, https or socks5 scheme, credentials can be set in its user info.
+	// Default is proxy from environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+	Proxy *url.URL
+	// NoProxy never use proxy, even the one from environment.
+	NoProxy bool
+
+	// DisableHTTP2 use HTTP/1.1 only, otherwise HTTP/2 is used for servers supporting it.
+	DisableHTTP2 bool
 
 	// Logger enable log for successfull or failed request.
 	EnableLogger bool
 
-	// MaxRetry maximum retry for transient errors. Ignored for POST or PATCH as it's not safe.
+	// MaxRetry maximum retry for transient errors. Ignored for POST or PATCH as it's not safe, unless RetryIdempotencyKey is set.
+	// Request body is replayed on each retry, and Retry-After header of 429 and 503 responses is respected.
 	MaxRetry int
 
+	// RetryIdempotencyKey if true then POST and PATCH requests with Idempotency-Key header are retried as well.
+	RetryIdempotencyKey bool
+
 	// RetryPolicy if MaxRetry is zero then this will be ignored.
 	// It can use customized retry policy, if MaxRetry is set and RetryPolicy is nil, then default will be used.
 	RetryPolicy func(*http.Response, error) bool
@@ -87,114 +120,61 @@ type Opts struct {
 	// MinBackOff minimum wait for backoff. If nil then default will be set.
 	MinBackOff *time.Duration
 	// MaxBackOff maximum wait for backoff. If nil then default will be set
+	// If Retry-After of the response is longer than this, the response is returned without retrying.
 	MaxBackOff *time.Duration
 
-	// Transport Optional. If you want to specify your own RoundTrip logic. Otherwise will be set to http.DefaultTransport.
-	// If MaxRetry is more than zero, then this Transport will be executed after retry RoundTripper.
+	// Hedging optional, send identical GET or HEAD requests if the first one is slow and take the fastest response.
+	// Executed inside retry, each attempt is hedged.
+	Hedging *Hedging
+
+	// CircuitBreaker optional, if set then requests to a host fail fast with CircuitOpenError while its circuit is open.
+	// Executed inside retry, open circuits are never retried.
+	CircuitBreaker *CircuitBreaker
+
+	// RateLimits optional, per host limits of request rate and concurrent requests, the first rule matching request applies.
+	// Executed inside retry, each attempt counts.
+	RateLimits []RateLimit
+	// RateLimitStore optional, if set then request rate is counted in the store shared by all replicas, e.g. *redis.Redis.
+	RateLimitStore RateLimitStore
+
+	// Cache optional, cache GET responses as instructed by Cache-Control and revalidate them with ETag or Last-Modified.
+	Cache *ResponseCache
+
+	// Auth optional, set credentials of every request, e.g. BearerToken, BasicAuth, HeaderAuth or *ClientCredentials.
+	Auth Auth
+
+	// Signer optional, sign every request with HMAC signature verified by server.VerifySignature.
+	Signer *_signature.Signer
+
+	// Tracer optional, each request is traced by client span whose context is sent in traceparent header,
+	// as child of span in request context, e.g. server span of incoming request. Default is trace.Default().
+	Tracer *_trace.Tracer
+
+	// Metrics optional, called once request is finished including all retries, e.g. to record latency and status code.
+	Metrics func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)
+
+	// Interceptors optional, custom interceptors placed before or after built-in or other custom interceptors.
+	Interceptors []InterceptorSpec
+
+	// Transport Optional. If you want to specify your own RoundTrip logic. Otherwise transport is created by NewTransport.
+	// Executed after all interceptors. Connection, timeout, TLS, proxy and HTTP/2 options are ignored if it's set.
 	Transport http.RoundTripper
 }
 
 func newClient(opts *Opts) *Client {
-	logger := log.New(os.Stderr, "", 0)
-	c := &Client{&http.Client{}}
-	if opts.Transport != nil {
-		c.Client.Transport = opts.Transport
-		if opts.EnableLogger {
-			l := &logRT{
-				logger: logger,
-				rt:     opts.Transport,
-			}
-			c.Client.Transport = l
-		}
-		if opts.MaxRetry > 0 {
-			transport := opts.Transport
-			if opts.EnableLogger {
-				l := &logRT{
-					logger: logger,
-					rt:     opts.Transport,
-				}
-				transport = l
-			}
-			re := &retry{
-				nums:       opts.MaxRetry,
-				rt:         transport,
-				retry:      defaultRetry,
-				backOff:    defaultBackOff,
-				minBackOff: defaultMinBackOff,
-				maxBackOff: defaultMaxBackOff,
-			}
-			if opts.RetryPolicy != nil {
-				re.retry = opts.RetryPolicy
-			}
-			if opts.BackOffPolicy != nil {
-				re.backOff = opts.BackOffPolicy
-			}
-			if opts.MinBackOff != nil {
-				re.minBackOff = *opts.MinBackOff
-			}
-			if opts.MaxBackOff != nil {
-				re.maxBackOff = *opts.MaxBackOff
-			}
-			c.Client.Transport = re
-		}
-	} else {
-		tr := http.DefaultTransport.(*http.Transport)
-		if opts.MaxIdleConns > 0 {
-			tr.MaxIdleConns = opts.MaxIdleConns
-		}
-		if opts.IdleConnTimeout > 0 {
-			tr.IdleConnTimeout = opts.IdleConnTimeout
-		}
-		var transport http.RoundTripper = tr
-		if opts.MaxRetry > 0 {
-			if opts.EnableLogger {
-				l := &logRT{
-					logger: logger,
-					rt:     tr,
-				}
-				transport = l
-			}
-			re := &retry{
-				nums:       opts.MaxRetry,
-				rt:         transport,
-				retry:      defaultRetry,
-				backOff:    defaultBackOff,
-				minBackOff: defaultMinBackOff,
-				maxBackOff: defaultMaxBackOff,
-			}
-			if opts.RetryPolicy != nil {
-				re.retry = opts.RetryPolicy
-			}
-			if opts.BackOffPolicy != nil {
-				re.backOff = opts.BackOffPolicy
-			}
-			if opts.MinBackOff != nil {
-				re.minBackOff = *opts.MinBackOff
-			}
-			if opts.MaxBackOff != nil {
-				re.maxBackOff = *opts.MaxBackOff
-			}
-			c.Client.Transport = re
-		} else {
-			if opts.EnableLogger {
-				l := &logRT{
-					logger: logger,
-					rt:     tr,
-				}
-				transport = l
-			}
-			c.Client.Transport = transport
-		}
+	transport := opts.Transport
+	if transport == nil {
+		transport = NewTransport(opts)
 	}
-	return c
+	logger := log.New(os.Stderr, "", 0)
+	return &Client{&http.Client{
+		Transport: chain(interceptors(opts, logger), transport),
+	}}
 }
 
+// New create client. Package-level functions keep using default client unless it's passed to SetDefault or WithDoer.
 func New(opts *Opts) *Client {
-	c := newClient(opts)
-	once.Do(func() {
-		client = c
-	})
-	return c
+	return newClient(opts)
 }
 
 // defaultRetry retry policy
@@ -202,6 +182,7 @@ func defaultRetry(resp *http.Response, err error) bool {
 	if resp != nil {
 		// transient http status codes
 		if resp.StatusCode == http.StatusRequestTimeout ||
+			resp.StatusCode == http.StatusTooManyRequests ||
 			resp.StatusCode == http.StatusServiceUnavailable ||
 			resp.StatusCode == http.StatusGatewayTimeout {
 			return true
@@ -222,43 +203,109 @@ func defaultBackOff(attempt int, min time.Duration, max time.Duration) time.Dura
 	return time.Duration(decorJitterExponentialBackOff(attempt, int64(min), int64(max)))
 }
 
-func (r *retry) RoundTrip(req *http.Request) (resp *http.Response, err error) {
-	// no retry for non-idempotent methods
-	if req.Method == http.MethodPost || req.Method == http.MethodPatch {
-		resp, err = r.rt.RoundTrip(req)
-	} else {
-		var (
-			duration time.Duration
-			ctx      context.Context
-			cancel   func()
-		)
-		if deadline, ok := req.Context().Deadline(); ok {
-			duration = time.Until(deadline)
-		}
-		for i := 0; i < r.nums; i++ {
-			if duration > 0 {
-				ctx, cancel = context.WithTimeout(context.Background(), duration)
-				req = req.WithContext(ctx)
-			}
-			resp, err = r.rt.RoundTrip(req)
-			if !r.retry(resp, err) {
-				if cancel != nil {
-					cancel()
-				}
-				return
+func (r *retry) intercept(req *http.Request, next RoundTripFunc) (resp *http.Response, err error) {
+	if !r.retryable(req) {
+		return next(req)
+	}
+	getBody, err := bodyGetter(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		// the original body is still unread on first attempt if request comes with GetBody.
+		if getBody != nil && (attempt > 0 || req.GetBody == nil) {
+			attemptReq = req.Clone(ctx)
+			if attemptReq.Body, err = getBody(); err != nil {
+				return nil, err
 			}
-			if cancel != nil {
-				cancel()
+			attemptReq.GetBody = getBody
+		}
+		resp, err = next(attemptReq)
+		if attempt >= r.nums || errors.Is(err, ErrCircuitOpen) || !r.retry(resp, err) {
+			return resp, err
+		}
+		wait := r.backOff(attempt, r.minBackOff, r.maxBackOff)
+		if after, ok := retryAfter(resp); ok {
+			if after > r.maxBackOff {
+				return resp, err
 			}
-			time.Sleep(r.backOff(i, r.minBackOff, r.maxBackOff))
+			wait = after
+		}
+		drain(resp)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
 		}
 	}
-	return
 }
 
-func (l *logRT) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+// retryable no retry for non-idempotent methods, unless it has idempotency key and it's enabled.
+func (r *retry) retryable(req *http.Request) bool {
+	if req.Method == http.MethodPost || req.Method == http.MethodPatch {
+		return r.idempotencyKey && req.Header.Get(headerIdempotencyKey) != ""
+	}
+	return true
+}
+
+// bodyGetter return function to obtain fresh copy of request body for each attempt.
+// Body is buffered in memory if request has no GetBody.
+func bodyGetter(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}, nil
+}
+
+// retryAfter parse Retry-After header of 429 and 503 responses, either in seconds or http date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// drain read the rest of discarded response body and close it, so the connection can be reused.
+func drain(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	io.CopyN(ioutil.Discard, resp.Body, maxDrainBody)
+	resp.Body.Close()
+}
+
+func (l *logging) intercept(req *http.Request, next RoundTripFunc) (resp *http.Response, err error) {
 	start := time.Now()
-	resp, err = l.rt.RoundTrip(req)
+	resp, err = next(req)
 	elapsed := time.Since(start)
 	if err != nil {
 		l.logger.Printf("%s | httpclient | %s | ERR | %s | %v | %v | %s\n", time.Now().Format(time.RFC3339), req.Method, fmt.Sprintf("%s%s", req.URL.Host, req.URL.Path), err, elapsed, req.Header.Get("Request-Id"))
@@ -275,11 +322,24 @@ func (l *logRT) RoundTrip(req *http.Request) (resp *http.Response, err error) {
 type Request struct {
 	BaseURL   string
 	Header    http.Header
-	URLValues url.Values        // for get method
-	Body      map[string]string // for x-www-form-urlencoded, json payload, and multipart/form non-binary data
-	Files     []File            // for multipart/form binary data
+	URLValues url.Values // for get method
+
+	// Body any value encodable by codec of ContentType, e.g. struct, map, slice, or proto.Message for protobuf.
+	// For x-www-form-urlencoded and multipart/form it must be map or struct, struct fields are named by `form` tag, fallback to `json` tag.
+	// If Body is io.Reader then it's sent as is.
+	Body  interface{}
+	Files []File // for multipart/form binary data
+
+	// ContentType of Body for Post, Put and Patch. Default is application/json.
+	ContentType string
 
 	RequestID string // unique identifier for each request. E.g. uuid v4. If empty, then will be set automatically using uuid v4.
+
+	// UploadProgress optional, called as request body is sent. Restarts from zero if body is replayed on retry.
+	UploadProgress ProgressFunc
+
+	// DownloadProgress optional, called as response body is received.
+	DownloadProgress ProgressFunc
 }
 
 type File struct {
@@ -333,25 +393,59 @@ func (r *Request) FormURLEncoded() (io.Reader, error) {
 	if err := r.init(); err != nil {
 		return nil, err
 	}
-	body := url.Values{}
-	for key, val := range r.Body {
-		body.Add(key, val)
+	if raw, ok := r.Body.(io.Reader); ok {
+		r.setContentType(mimeFormURLEncoded)
+		return raw, nil
+	}
+	body, _, err := formValues(r.Body)
+	if err != nil {
+		return nil, err
 	}
-	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Content-Type", mimeFormURLEncoded)
 	return strings.NewReader(body.Encode()), nil
 }
 
 // JSON create application/json payload from body
 func (r *Request) JSON() (io.Reader, error) {
+	return r.Encode(_codec.MIMEJSON)
+}
+
+// Encode create payload from body using codec registered for content type.
+// x-www-form-urlencoded and multipart/form-data are encoded with FormURLEncoded and MultipartForm.
+func (r *Request) Encode(contentType string) (io.Reader, error) {
 	if err := r.init(); err != nil {
 		return nil, err
 	}
-	buf, err := json.Marshal(r.Body)
+	if contentType == "" {
+		contentType = _codec.MIMEJSON
+	}
+	switch mediaType(contentType) {
+	case mimeFormURLEncoded:
+		return r.FormURLEncoded()
+	case mimeMultipartForm:
+		return r.MultipartForm()
+	}
+	if raw, ok := r.Body.(io.Reader); ok {
+		r.setContentType(contentType)
+		return raw, nil
+	}
+	c, ok := _codec.Get(contentType)
+	if !ok {
+		return nil, ErrUnsupportedContentType
+	}
+	buf, err := c.Marshal(r.Body)
 	if err != nil {
 		return nil, err
 	}
-	r.Header.Set("Content-Type", "application/json")
-	return bytes.NewBuffer(buf), nil
+	r.Header.Set("Content-Type", contentType)
+	return bytes.NewReader(buf), nil
+}
+
+// setContentType set Content-Type header for raw body if not set already.
+func (r *Request) setContentType(contentType string) {
+	if r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", contentType)
+	}
 }
 
 // MultipartForm create multipart/form non-binary and binary data
@@ -359,17 +453,26 @@ func (r *Request) MultipartForm() (io.Reader, error) {
 	if err := r.init(); err != nil {
 		return nil, err
 	}
+	if raw, ok := r.Body.(io.Reader); ok {
+		r.setContentType(mimeMultipartForm)
+		return raw, nil
+	}
+	values, files, err := formValues(r.Body)
+	if err != nil {
+		return nil, err
+	}
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 	// non-binary body
-	for key, val := range r.Body {
-		err := writer.WriteField(key, fmt.Sprintf("%v", val))
-		if err != nil {
-			return nil, err
+	for key, vals := range values {
+		for _, val := range vals {
+			if err := writer.WriteField(key, val); err != nil {
+				return nil, err
+			}
 		}
 	}
 	// binary body
-	for _, v := range r.Files {
+	for _, v := range append(files, r.Files...) {
 		part, err := writer.CreateFormFile(v.FieldName, v.FileName)
 		if err != nil {
 			return nil, err
@@ -381,7 +484,7 @@ func (r *Request) MultipartForm() (io.Reader, error) {
 	}
 
 	r.Header.Set("Content-Type", writer.FormDataContentType())
-	err := writer.Close()
+	err = writer.Close()
 	if err != nil {
 		return nil, err
 	}
@@ -390,11 +493,16 @@ func (r *Request) MultipartForm() (io.Reader, error) {
 }
 
 // Scan fetch r.Body into destination in form of structure or type.
+// Decoded with codec registered for response Content-Type, JSON if none registered.
 func (r *Response) Scan(destination interface{}) error {
 	if r.Error != nil {
 		return r.Error
 	}
-	return json.Unmarshal(r.Body, destination)
+	c, ok := _codec.Get(r.Header.Get("Content-Type"))
+	if !ok {
+		c = _codec.Default()
+	}
+	return c.Unmarshal(r.Body, destination)
 }
 
 // String convert response body to string.
@@ -414,35 +522,39 @@ func (r *Response) Err() error {
 	return r.Error
 }
 
-func (c *Client) do(ctx context.Context, method string, urlQuery string, header http.Header, body io.Reader) (*http.Response, error) {
+// send request without reading response body.
+func (c *Client) send(ctx context.Context, method string, req *Request, body io.Reader) (*http.Response, error) {
+	if req == nil {
+		return nil, ErrRequestNil
+	}
+	urlQuery, err := req.URLQuery()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Request-Id", req.RequestID)
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	req, err := http.NewRequestWithContext(ctx, method, urlQuery, body)
+	httpReq, err := http.NewRequestWithContext(ctx, method, urlQuery, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header = header
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Date", time.Now().Format(time.RFC1123))
-	return c.Do(req)
+	httpReq.Header = req.Header
+	httpReq.Header.Set("Connection", "keep-alive")
+	httpReq.Header.Set("Date", time.Now().Format(time.RFC1123))
+	if req.UploadProgress != nil && body != nil {
+		trackUpload(httpReq, req.UploadProgress)
+	}
+	return c.Do(httpReq)
 }
 
 func (c *Client) call(ctx context.Context, method string, req *Request, body io.Reader) *Response {
-	if req == nil {
-		return &Response{Error: ErrRequestNil}
-	}
-	urlQuery, err := req.URLQuery()
-	if err != nil {
-		return &Response{Error: err}
-	}
-	req.Header.Set("Request-Id", req.RequestID)
-	resp, err := c.do(ctx, method, urlQuery, req.Header, body)
+	resp, err := c.send(ctx, method, req, body)
 	if err != nil {
 		return &Response{Error: err}
 	}
 	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
+	respBody, err := ioutil.ReadAll(trackDownload(resp, req.DownloadProgress, 0))
 	if err != nil {
 		return &Response{Error: err}
 	}
@@ -466,6 +578,18 @@ func (c *Client) Options(ctx context.Context, req *Request) *Response {
 	return c.call(ctx, http.MethodOptions, req, nil)
 }
 
+// Post send body encoded as req.ContentType.
+func (c *Client) Post(ctx context.Context, req *Request) *Response {
+	if req == nil {
+		return &Response{Error: ErrRequestNil}
+	}
+	body, err := req.Encode(req.ContentType)
+	if err != nil {
+		return &Response{Error: err}
+	}
+	return c.call(ctx, http.MethodPost, req, body)
+}
+
 func (c *Client) PostJSON(ctx context.Context, req *Request) *Response {
 	body, err := req.JSON()
 	if err != nil {
@@ -490,6 +614,18 @@ func (c *Client) PostMultipart(ctx context.Context, req *Request) *Response {
 	return c.call(ctx, http.MethodPost, req, body)
 }
 
+// Put send body encoded as req.ContentType.
+func (c *Client) Put(ctx context.Context, req *Request) *Response {
+	if req == nil {
+		return &Response{Error: ErrRequestNil}
+	}
+	body, err := req.Encode(req.ContentType)
+	if err != nil {
+		return &Response{Error: err}
+	}
+	return c.call(ctx, http.MethodPut, req, body)
+}
+
 func (c *Client) PutJSON(ctx context.Context, req *Request) *Response {
 	body, err := req.JSON()
 	if err != nil {
@@ -514,6 +650,18 @@ func (c *Client) PutMultipart(ctx context.Context, req *Request) *Response {
 	return c.call(ctx, http.MethodPut, req, body)
 }
 
+// Patch send body encoded as req.ContentType.
+func (c *Client) Patch(ctx context.Context, req *Request) *Response {
+	if req == nil {
+		return &Response{Error: ErrRequestNil}
+	}
+	body, err := req.Encode(req.ContentType)
+	if err != nil {
+		return &Response{Error: err}
+	}
+	return c.call(ctx, http.MethodPatch, req, body)
+}
+
 func (c *Client) PatchJSON(ctx context.Context, req *Request) *Response {
 	body, err := req.JSON()
 	if err != nil {
@@ -548,135 +696,89 @@ func (c *Client) Delete(ctx context.Context, req *Request) *Response {
 
 // ---------------------------------------------------
 // Instant functions without client object.
-// If called without/before initiating client object, then these functions have no logger and retry option.
+// They use Doer of ctx set by WithDoer, otherwise default client which has no logger and retry option unless replaced by SetDefault.
 // ---------------------------------------------------
 
 func Get(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.Get(ctx, req)
+	return FromContext(ctx).Get(ctx, req)
 }
 
 func Head(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.Head(ctx, req)
+	return FromContext(ctx).Head(ctx, req)
 }
 
 func Options(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.Options(ctx, req)
+	return FromContext(ctx).Options(ctx, req)
+}
+
+func Post(ctx context.Context, req *Request) *Response {
+	return FromContext(ctx).Post(ctx, req)
 }
 
 func PostJSON(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PostJSON(ctx, req)
+	return FromContext(ctx).PostJSON(ctx, req)
 }
 
 func PostForm(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PostForm(ctx, req)
+	return FromContext(ctx).PostForm(ctx, req)
 }
 
 func PostMultipart(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PostMultipart(ctx, req)
+	return FromContext(ctx).PostMultipart(ctx, req)
+}
+
+func Put(ctx context.Context, req *Request) *Response {
+	return FromContext(ctx).Put(ctx, req)
 }
 
 func PutJSON(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PutJSON(ctx, req)
+	return FromContext(ctx).PutJSON(ctx, req)
 }
 
 func PutForm(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PutForm(ctx, req)
+	return FromContext(ctx).PutForm(ctx, req)
 }
 
 func PutMultipart(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PutMultipart(ctx, req)
+	return FromContext(ctx).PutMultipart(ctx, req)
+}
+
+func Patch(ctx context.Context, req *Request) *Response {
+	return FromContext(ctx).Patch(ctx, req)
 }
 
 func PatchJSON(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PatchJSON(ctx, req)
+	return FromContext(ctx).PatchJSON(ctx, req)
 }
 
 func PatchForm(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PatchForm(ctx, req)
+	return FromContext(ctx).PatchForm(ctx, req)
 }
 
 func PatchMultipart(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.PatchMultipart(ctx, req)
+	return FromContext(ctx).PatchMultipart(ctx, req)
 }
 
 func Delete(ctx context.Context, req *Request) *Response {
-	once.Do(func() {
-		client = newClient(&Opts{
-			MaxIdleConns:    100,
-			IdleConnTimeout: 30,
-		})
-	})
-	return client.Delete(ctx, req)
+	return FromContext(ctx).Delete(ctx, req)
+}
+
+func Stream(ctx context.Context, method string, req *Request) *StreamResponse {
+	return FromContext(ctx).Stream(ctx, method, req)
+}
+
+func Download(ctx context.Context, req *Request, path string, opts *DownloadOpts) (int64, error) {
+	return FromContext(ctx).Download(ctx, req, path, opts)
+}
+
+func Batch(ctx context.Context, opts *BatchOpts, reqs ...BatchRequest) ([]*Response, error) {
+	return FromContext(ctx).Batch(ctx, opts, reqs...)
+}
+
+func Pages(ctx context.Context, req *Request, opts *PageOpts) *PageIterator {
+	return FromContext(ctx).Pages(ctx, req, opts)
+}
+
+func Items(ctx context.Context, req *Request, opts *PageOpts) *ItemIterator {
+	return FromContext(ctx).Items(ctx, req, opts)
 }
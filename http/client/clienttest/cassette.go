@@ -0,0 +1,286 @@
+package clienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// Redacted replacement of scrubbed values.
+	Redacted = "[REDACTED]"
+)
+
+var (
+	ErrInteractionNotFound = errors.New("clienttest: interaction not found in cassette")
+
+	// DefaultScrubHeaders headers scrubbed if RecorderOpts.ScrubHeaders is nil.
+	DefaultScrubHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Signature"}
+)
+
+// Mode of Recorder.
+type Mode int
+
+const (
+	// ModeAuto replay if cassette file exists, otherwise record it.
+	ModeAuto Mode = iota
+	// ModeReplay replay only, requests not in cassette fail with ErrInteractionNotFound.
+	ModeReplay
+	// ModeRecord send every request and overwrite cassette.
+	ModeRecord
+)
+
+// Cassette recorded interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request" yaml:"request"`
+	Response RecordedResponse `json:"response" yaml:"response"`
+
+	replayed bool
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method" yaml:"method"`
+	URL    string      `json:"url" yaml:"url"`
+	Header http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body   string      `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code" yaml:"status_code"`
+	Header     http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body       string      `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// RecorderOpts options of Recorder.
+type RecorderOpts struct {
+	// Path of cassette file, saved as JSON if extension is .json, otherwise YAML.
+	Path string
+
+	Mode Mode
+
+	// Transport used for recording. Default is http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// ScrubHeaders headers of requests and responses saved as Redacted. Default is DefaultScrubHeaders.
+	ScrubHeaders []string
+
+	// ScrubQuery query params saved as Redacted, e.g. api_key.
+	ScrubQuery []string
+
+	// Scrub optional, called on every interaction before it's saved, e.g. to remove secrets from bodies.
+	// If request body is changed then Matcher must be set, as default one compares request bodies.
+	Scrub func(*Interaction)
+
+	// Matcher optional, report whether interaction is recorded for request. Default matches method, url and body.
+	// Request url and header are scrubbed already, so they can be compared with recorded ones.
+	Matcher func(req *RecordedRequest, i *Interaction) bool
+}
+
+// Recorder http.RoundTripper recording interactions into cassette and replaying them offline.
+// Identical requests are replayed in recorded order, the last one repeats.
+type Recorder struct {
+	opts      RecorderOpts
+	recording bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+// NewRecorder create recorder, cassette is loaded if it's replayed.
+func NewRecorder(opts *RecorderOpts) (*Recorder, error) {
+	r := &Recorder{opts: *opts, cassette: &Cassette{}}
+	if r.opts.Transport == nil {
+		r.opts.Transport = http.DefaultTransport
+	}
+	if r.opts.ScrubHeaders == nil {
+		r.opts.ScrubHeaders = DefaultScrubHeaders
+	}
+	if r.opts.Matcher == nil {
+		r.opts.Matcher = defaultMatcher
+	}
+
+	switch r.opts.Mode {
+	case ModeRecord:
+		r.recording = true
+		return r, nil
+	case ModeAuto:
+		if _, err := os.Stat(r.opts.Path); os.IsNotExist(err) {
+			r.recording = true
+			return r, nil
+		}
+	}
+	b, err := ioutil.ReadFile(r.opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	if r.json() {
+		err = json.Unmarshal(b, r.cassette)
+	} else {
+		err = _yaml.Unmarshal(b, r.cassette)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Recording report whether requests are sent and recorded, otherwise they're replayed.
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	recorded := r.recordRequest(req, body)
+
+	if !r.recording {
+		r.mu.Lock()
+		i := r.find(recorded)
+		r.mu.Unlock()
+		if i == nil {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, recorded.Method, recorded.URL)
+		}
+		return NewResponse(req, i.Response.StatusCode, i.Response.Header, []byte(i.Response.Body)), nil
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := r.opts.Transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := &Interaction{
+		Request: *recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.scrubHeader(resp.Header),
+			Body:       string(respBody),
+		},
+	}
+	if r.opts.Scrub != nil {
+		r.opts.Scrub(i)
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Stop save cassette if recording, call it at the end of test.
+func (r *Recorder) Stop() error {
+	if !r.recording {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var (
+		b   []byte
+		err error
+	)
+	if r.json() {
+		b, err = json.MarshalIndent(r.cassette, "", "  ")
+	} else {
+		b, err = _yaml.Marshal(r.cassette)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.opts.Path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.opts.Path, b, 0644)
+}
+
+func (r *Recorder) json() bool {
+	return strings.EqualFold(filepath.Ext(r.opts.Path), ".json")
+}
+
+// find return the first matching interaction not replayed yet, or the last matching one.
+func (r *Recorder) find(req *RecordedRequest) *Interaction {
+	var last *Interaction
+	for _, i := range r.cassette.Interactions {
+		if !r.opts.Matcher(req, i) {
+			continue
+		}
+		if !i.replayed {
+			i.replayed = true
+			return i
+		}
+		last = i
+	}
+	return last
+}
+
+func (r *Recorder) recordRequest(req *http.Request, body []byte) *RecordedRequest {
+	u := *req.URL
+	if len(r.opts.ScrubQuery) > 0 {
+		query := u.Query()
+		for _, k := range r.opts.ScrubQuery {
+			if _, ok := query[k]; ok {
+				query.Set(k, Redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return &RecordedRequest{
+		Method: req.Method,
+		URL:    u.String(),
+		Header: r.scrubHeader(req.Header),
+		Body:   string(body),
+	}
+}
+
+func (r *Recorder) scrubHeader(header http.Header) http.Header {
+	h := header.Clone()
+	for _, k := range r.opts.ScrubHeaders {
+		if _, ok := h[http.CanonicalHeaderKey(k)]; ok {
+			h.Set(k, Redacted)
+		}
+	}
+	return h
+}
+
+func defaultMatcher(req *RecordedRequest, i *Interaction) bool {
+	return req.Method == i.Request.Method && sameURL(req.URL, i.Request.URL) && req.Body == i.Request.Body
+}
+
+// sameURL compare urls regardless of query order.
+func sameURL(a string, b string) bool {
+	ua, err1 := url.Parse(a)
+	ub, err2 := url.Parse(b)
+	if err1 != nil || err2 != nil {
+		return a == b
+	}
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host && ua.Path == ub.Path && ua.Query().Encode() == ub.Query().Encode()
+}
@@ -0,0 +1,347 @@
+package clienttest
+
+/*
+	Test helpers for code calling http/client, or any http.Client.
+
+	Mock transport:
+		mock := clienttest.NewTransport()
+		mock.On(http.MethodGet, "/users/*").WithQuery("active", "true").ReplyJSON(200, users)
+		c := client.New(&client.Opts{Transport: mock})
+		...
+		mock.AssertExpectations(t)
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	ErrNoMatch = errors.New("clienttest: no stub matched request")
+)
+
+// Transport programmable mock http.RoundTripper, requests are answered by the first matching stub.
+type Transport struct {
+	mu        sync.Mutex
+	stubs     []*Stub
+	calls     []*Call
+	unmatched []*Call
+}
+
+// Call request received by Transport, body is read already.
+type Call struct {
+	Request *http.Request
+	Body    []byte
+}
+
+// NewTransport create mock transport without stubs, unmatched requests fail with ErrNoMatch.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// On register stub matching method and path, path can be pattern in path.Match syntax, e.g. /users/*.
+// Empty method matches any method.
+func (t *Transport) On(method string, path string) *Stub {
+	s := &Stub{transport: t, method: method, path: path}
+	t.mu.Lock()
+	t.stubs = append(t.stubs, s)
+	t.mu.Unlock()
+	return s
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	call := &Call{Request: req, Body: body}
+
+	t.mu.Lock()
+	t.calls = append(t.calls, call)
+	var stub *Stub
+	for _, s := range t.stubs {
+		if s.matches(req, body) {
+			stub = s
+			break
+		}
+	}
+	if stub == nil {
+		t.unmatched = append(t.unmatched, call)
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrNoMatch, req.Method, req.URL)
+	}
+	reply := stub.next()
+	t.mu.Unlock()
+
+	return reply(req)
+}
+
+// Calls return all requests received, in order.
+func (t *Transport) Calls() []*Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	calls := make([]*Call, len(t.calls))
+	copy(calls, t.calls)
+	return calls
+}
+
+// AssertCalled assert requests matching method and path are received exactly times.
+func (t *Transport) AssertCalled(tb testing.TB, method string, path string, times int) bool {
+	tb.Helper()
+	probe := &Stub{method: method, path: path}
+	n := 0
+	for _, c := range t.Calls() {
+		if probe.matches(c.Request, c.Body) {
+			n++
+		}
+	}
+	if n != times {
+		tb.Errorf("clienttest: expected %d calls of %s %s, got %d", times, method, path, n)
+		return false
+	}
+	return true
+}
+
+// AssertExpectations assert every stub is called, exactly n times if it's limited by Times(n), and no request is unmatched.
+func (t *Transport) AssertExpectations(tb testing.TB) bool {
+	tb.Helper()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ok := true
+	for _, s := range t.stubs {
+		switch {
+		case s.times > 0 && s.calls != s.times:
+			tb.Errorf("clienttest: expected %d calls of %s %s, got %d", s.times, s.method, s.path, s.calls)
+			ok = false
+		case s.calls == 0:
+			tb.Errorf("clienttest: expected call of %s %s", s.method, s.path)
+			ok = false
+		}
+	}
+	for _, c := range t.unmatched {
+		tb.Errorf("clienttest: unexpected call %s %s", c.Request.Method, c.Request.URL)
+		ok = false
+	}
+	return ok
+}
+
+type replyFunc func(req *http.Request) (*http.Response, error)
+
+// Stub matcher of requests and their canned responses.
+type Stub struct {
+	transport *Transport
+	method    string
+	path      string
+	query     url.Values
+	header    http.Header
+	body      func([]byte) bool
+	match     func(req *http.Request, body []byte) bool
+	replies   []replyFunc
+	times     int
+	calls     int
+}
+
+// WithQuery match requests having query key with value.
+func (s *Stub) WithQuery(key string, value string) *Stub {
+	if s.query == nil {
+		s.query = url.Values{}
+	}
+	s.query.Add(key, value)
+	return s
+}
+
+// WithHeader match requests having header key with value.
+func (s *Stub) WithHeader(key string, value string) *Stub {
+	if s.header == nil {
+		s.header = http.Header{}
+	}
+	s.header.Add(key, value)
+	return s
+}
+
+// WithBody match requests which body is exactly body.
+func (s *Stub) WithBody(body string) *Stub {
+	s.body = func(b []byte) bool {
+		return string(b) == body
+	}
+	return s
+}
+
+// WithJSONBody match requests which JSON body is equal to v regardless of formatting and key order.
+func (s *Stub) WithJSONBody(v interface{}) *Stub {
+	expected, err := normalizeJSON(v)
+	s.body = func(b []byte) bool {
+		var actual interface{}
+		if err != nil || json.Unmarshal(b, &actual) != nil {
+			return false
+		}
+		return reflect.DeepEqual(expected, actual)
+	}
+	return s
+}
+
+// Match match requests with custom matcher, in addition to method and path.
+func (s *Stub) Match(fn func(req *http.Request, body []byte) bool) *Stub {
+	s.match = fn
+	return s
+}
+
+// Times stub matches at most n requests, further requests fall through to the next stub.
+func (s *Stub) Times(n int) *Stub {
+	s.times = n
+	return s
+}
+
+// Reply respond with status and body. Multiple replies are returned in order, the last one repeats.
+func (s *Stub) Reply(status int, body string) *Stub {
+	return s.ReplyHeader(status, nil, body)
+}
+
+// ReplyHeader respond with status, header and body.
+func (s *Stub) ReplyHeader(status int, header http.Header, body string) *Stub {
+	return s.ReplyFunc(func(req *http.Request) (*http.Response, error) {
+		return NewResponse(req, status, header, []byte(body)), nil
+	})
+}
+
+// ReplyJSON respond with status and v encoded as JSON.
+func (s *Stub) ReplyJSON(status int, v interface{}) *Stub {
+	b, err := json.Marshal(v)
+	return s.ReplyFunc(func(req *http.Request) (*http.Response, error) {
+		if err != nil {
+			return nil, err
+		}
+		return NewResponse(req, status, http.Header{"Content-Type": []string{"application/json"}}, b), nil
+	})
+}
+
+// ReplyError fail request with err, e.g. to simulate network errors.
+func (s *Stub) ReplyError(err error) *Stub {
+	return s.ReplyFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, err
+	})
+}
+
+// ReplyFunc respond with fn.
+func (s *Stub) ReplyFunc(fn func(req *http.Request) (*http.Response, error)) *Stub {
+	s.replies = append(s.replies, fn)
+	return s
+}
+
+// Calls return number of requests matched by stub.
+func (s *Stub) Calls() int {
+	s.transport.mu.Lock()
+	defer s.transport.mu.Unlock()
+	return s.calls
+}
+
+func (s *Stub) matches(req *http.Request, body []byte) bool {
+	if s.times > 0 && s.calls >= s.times {
+		return false
+	}
+	if s.method != "" && !strings.EqualFold(s.method, req.Method) {
+		return false
+	}
+	if s.path != "" && s.path != req.URL.Path {
+		if ok, _ := path.Match(s.path, req.URL.Path); !ok {
+			return false
+		}
+	}
+	query := req.URL.Query()
+	for k, vals := range s.query {
+		for _, v := range vals {
+			if !contains(query[k], v) {
+				return false
+			}
+		}
+	}
+	for k, vals := range s.header {
+		for _, v := range vals {
+			if !contains(req.Header.Values(k), v) {
+				return false
+			}
+		}
+	}
+	if s.body != nil && !s.body(body) {
+		return false
+	}
+	if s.match != nil && !s.match(req, body) {
+		return false
+	}
+	return true
+}
+
+// next count the call and return its reply, must be called with Transport lock held.
+func (s *Stub) next() replyFunc {
+	s.calls++
+	if len(s.replies) == 0 {
+		return func(req *http.Request) (*http.Response, error) {
+			return NewResponse(req, http.StatusOK, nil, nil), nil
+		}
+	}
+	i := s.calls - 1
+	if i >= len(s.replies) {
+		i = len(s.replies) - 1
+	}
+	return s.replies[i]
+}
+
+// NewResponse create response of req.
+func NewResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func contains(vals []string, v string) bool {
+	for _, val := range vals {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	var b []byte
+	switch x := v.(type) {
+	case string:
+		b = []byte(x)
+	case []byte:
+		b = x
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var out interface{}
+	err := json.Unmarshal(b, &out)
+	return out, err
+}
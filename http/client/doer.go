@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Doer methods of Client. Accept Doer instead of *Client, so callers can pass their own client and tests can inject fakes.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+
+	Get(ctx context.Context, req *Request) *Response
+	Head(ctx context.Context, req *Request) *Response
+	Options(ctx context.Context, req *Request) *Response
+	Post(ctx context.Context, req *Request) *Response
+	PostJSON(ctx context.Context, req *Request) *Response
+	PostForm(ctx context.Context, req *Request) *Response
+	PostMultipart(ctx context.Context, req *Request) *Response
+	Put(ctx context.Context, req *Request) *Response
+	PutJSON(ctx context.Context, req *Request) *Response
+	PutForm(ctx context.Context, req *Request) *Response
+	PutMultipart(ctx context.Context, req *Request) *Response
+	Patch(ctx context.Context, req *Request) *Response
+	PatchJSON(ctx context.Context, req *Request) *Response
+	PatchForm(ctx context.Context, req *Request) *Response
+	PatchMultipart(ctx context.Context, req *Request) *Response
+	Delete(ctx context.Context, req *Request) *Response
+
+	Stream(ctx context.Context, method string, req *Request) *StreamResponse
+	Download(ctx context.Context, req *Request, path string, opts *DownloadOpts) (int64, error)
+	Batch(ctx context.Context, opts *BatchOpts, reqs ...BatchRequest) ([]*Response, error)
+	Pages(ctx context.Context, req *Request, opts *PageOpts) *PageIterator
+	Items(ctx context.Context, req *Request, opts *PageOpts) *ItemIterator
+}
+
+var _ Doer = (*Client)(nil)
+
+var (
+	defaultMu   sync.RWMutex
+	defaultDoer Doer
+)
+
+type doerKey struct{}
+
+// Default return Doer used by package-level functions. It's created with default options unless replaced by SetDefault.
+func Default() Doer {
+	defaultMu.RLock()
+	d := defaultDoer
+	defaultMu.RUnlock()
+	if d != nil {
+		return d
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultDoer == nil {
+		defaultDoer = newClient(&Opts{
+			MaxIdleConns:    100,
+			IdleConnTimeout: 30 * time.Second,
+		})
+	}
+	return defaultDoer
+}
+
+// SetDefault replace Doer used by package-level functions, e.g. with client created by New. Nil restores the built-in one.
+func SetDefault(d Doer) {
+	defaultMu.Lock()
+	defaultDoer = d
+	defaultMu.Unlock()
+}
+
+// WithDoer return ctx overriding default Doer for package-level functions called with it.
+func WithDoer(ctx context.Context, d Doer) context.Context {
+	return context.WithValue(ctx, doerKey{}, d)
+}
+
+// FromContext return Doer set by WithDoer, or Default if none.
+func FromContext(ctx context.Context) Doer {
+	if ctx != nil {
+		if d, ok := ctx.Value(doerKey{}).(Doer); ok && d != nil {
+			return d
+		}
+	}
+	return Default()
+}
@@ -0,0 +1,313 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHedgeBudget     = 0.05
+	maxHedgeBudgetTokens   = 10
+	hedgeLatencySamples    = 1000
+	minHedgeLatencySamples = 20
+	hedgeRecomputeEvery    = 50
+)
+
+// Hedging options of hedged requests. If request hasn't returned after a delay, identical request is sent,
+// the first successful response wins and the others are cancelled.
+type Hedging struct {
+	// Delay wait before sending each hedged request. Used until there are enough samples for Percentile.
+	Delay time.Duration
+
+	// Percentile optional, e.g. 0.95, delay is this percentile of recent latencies of the host.
+	Percentile float64
+
+	// MaxHedges maximum extra requests per request. Default is 1.
+	MaxHedges int
+
+	// Budget maximum ratio of hedged requests to requests, e.g. 0.05 means at most 5% extra requests. Default is 0.05.
+	Budget float64
+
+	// Methods hedged, they must be idempotent. Default is GET and HEAD. Requests with body are never hedged.
+	Methods []string
+
+	// OnHedge optional, called after request is hedged or hedging is denied by Budget.
+	OnHedge func(req *http.Request, report HedgeReport)
+}
+
+// HedgeReport result of hedged request.
+type HedgeReport struct {
+	// Hedges extra requests sent.
+	Hedges int
+	// Winner index of request whose response is returned, 0 is the original. -1 if none succeeded.
+	Winner int
+	// BudgetExceeded true if hedge is denied by budget.
+	BudgetExceeded bool
+	Elapsed        time.Duration
+}
+
+type hedger struct {
+	opts    Hedging
+	methods map[string]bool
+
+	mu        sync.Mutex
+	tokens    float64
+	latencies map[string]*latencies
+}
+
+type hedgeResult struct {
+	index   int
+	resp    *http.Response
+	err     error
+	elapsed time.Duration
+	cancel  context.CancelFunc
+}
+
+func newHedger(h *Hedging) *hedger {
+	opts := *h
+	if opts.MaxHedges <= 0 {
+		opts.MaxHedges = 1
+	}
+	if opts.Budget <= 0 {
+		opts.Budget = defaultHedgeBudget
+	}
+	if len(opts.Methods) == 0 {
+		opts.Methods = []string{http.MethodGet, http.MethodHead}
+	}
+	methods := make(map[string]bool, len(opts.Methods))
+	for _, m := range opts.Methods {
+		methods[m] = true
+	}
+	return &hedger{
+		opts:      opts,
+		methods:   methods,
+		latencies: make(map[string]*latencies),
+	}
+}
+
+func (h *hedger) intercept(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+	if !h.methods[req.Method] || (req.Body != nil && req.Body != http.NoBody) {
+		return next(req)
+	}
+	h.deposit()
+	host := req.URL.Host
+	delay := h.delay(host)
+	if delay <= 0 {
+		start := time.Now()
+		resp, err := next(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			h.record(host, time.Since(start))
+		}
+		return resp, err
+	}
+
+	start := time.Now()
+	results := make(chan hedgeResult, h.opts.MaxHedges+1)
+	cancels := make([]context.CancelFunc, 0, h.opts.MaxHedges+1)
+	launch := func(i int) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		r := req.Clone(ctx)
+		go func() {
+			sent := time.Now()
+			resp, err := next(r)
+			results <- hedgeResult{index: i, resp: resp, err: err, elapsed: time.Since(sent), cancel: cancel}
+		}()
+	}
+	// abandon cancel in-flight requests except winner and close their responses.
+	abandon := func(winner int, pending int) {
+		for i, cancel := range cancels {
+			if i != winner {
+				cancel()
+			}
+		}
+		go func() {
+			for ; pending > 0; pending-- {
+				if res := <-results; res.resp != nil {
+					res.resp.Body.Close()
+				}
+			}
+		}()
+	}
+
+	report := HedgeReport{Winner: -1}
+	defer func() {
+		report.Elapsed = time.Since(start)
+		if h.opts.OnHedge != nil && (report.Hedges > 0 || report.BudgetExceeded) {
+			h.opts.OnHedge(req, report)
+		}
+	}()
+
+	launch(0)
+	pending := 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var last *hedgeResult
+	for {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil && res.resp.StatusCode < http.StatusInternalServerError {
+				if last != nil && last.resp != nil {
+					last.resp.Body.Close()
+				}
+				report.Winner = res.index
+				h.record(host, res.elapsed)
+				abandon(res.index, pending)
+				res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: res.cancel}
+				return res.resp, nil
+			}
+			if last != nil && last.resp != nil {
+				last.resp.Body.Close()
+			}
+			last = &res
+			if pending > 0 {
+				continue
+			}
+			// every request failed, hedge right away if allowed.
+			if report.Hedges < h.opts.MaxHedges && req.Context().Err() == nil && h.withdraw() {
+				report.Hedges++
+				launch(report.Hedges)
+				pending++
+				continue
+			}
+			abandon(last.index, 0)
+			if last.resp != nil {
+				last.resp.Body = &cancelBody{ReadCloser: last.resp.Body, cancel: last.cancel}
+			} else {
+				last.cancel()
+			}
+			return last.resp, last.err
+		case <-timer.C:
+			if report.Hedges >= h.opts.MaxHedges {
+				continue
+			}
+			if !h.withdraw() {
+				report.BudgetExceeded = true
+				continue
+			}
+			report.Hedges++
+			launch(report.Hedges)
+			pending++
+			timer.Reset(delay)
+		case <-req.Context().Done():
+			abandon(-1, pending)
+			if last != nil && last.resp != nil {
+				last.resp.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// deposit add budget of one request.
+func (h *hedger) deposit() {
+	h.mu.Lock()
+	h.tokens += h.opts.Budget
+	if h.tokens > maxHedgeBudgetTokens {
+		h.tokens = maxHedgeBudgetTokens
+	}
+	h.mu.Unlock()
+}
+
+// withdraw take budget of one hedged request, false if budget is exhausted.
+func (h *hedger) withdraw() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+func (h *hedger) delay(host string) time.Duration {
+	if h.opts.Percentile <= 0 {
+		return h.opts.Delay
+	}
+	h.mu.Lock()
+	l, ok := h.latencies[host]
+	h.mu.Unlock()
+	if !ok {
+		return h.opts.Delay
+	}
+	if d, ok := l.percentile(h.opts.Percentile); ok {
+		return d
+	}
+	return h.opts.Delay
+}
+
+func (h *hedger) record(host string, elapsed time.Duration) {
+	if h.opts.Percentile <= 0 {
+		return
+	}
+	h.mu.Lock()
+	l, ok := h.latencies[host]
+	if !ok {
+		l = &latencies{samples: make([]time.Duration, 0, hedgeLatencySamples)}
+		h.latencies[host] = l
+	}
+	h.mu.Unlock()
+	l.add(elapsed)
+}
+
+// latencies ring of recent latencies.
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	added   int
+	value   time.Duration
+	valid   bool
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < cap(l.samples) {
+		l.samples = append(l.samples, d)
+	} else {
+		l.samples[l.next] = d
+		l.next = (l.next + 1) % len(l.samples)
+	}
+	l.added++
+	if l.added%hedgeRecomputeEvery == 0 {
+		l.valid = false
+	}
+}
+
+// percentile return p percentile of samples, recomputed every few samples. False if there are not enough samples.
+func (l *latencies) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < minHedgeLatencySamples {
+		return 0, false
+	}
+	if !l.valid {
+		sorted := make([]time.Duration, len(l.samples))
+		copy(sorted, l.samples)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		i := int(p * float64(len(sorted)-1))
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+		l.value, l.valid = sorted[i], true
+	}
+	return l.value, true
+}
+
+// cancelBody release context of response once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
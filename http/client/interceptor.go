@@ -0,0 +1,256 @@
+package client
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	_uuid "github.com/google/uuid"
+	_signature "github.com/mfathirirhas/godevkit/http/signature"
+	_trace "github.com/mfathirirhas/godevkit/trace"
+)
+
+// Names of built-in interceptors, ordered from outermost to innermost.
+// Disabled built-in interceptors are skipped but still can be referred by InterceptorSpec.
+const (
+	InterceptorRequestID      = "request-id"
+	InterceptorTrace          = "trace"
+	InterceptorMetrics        = "metrics"
+	InterceptorCache          = "cache"
+	InterceptorRetry          = "retry"
+	InterceptorHedge          = "hedge"
+	InterceptorRateLimit      = "ratelimit"
+	InterceptorCircuitBreaker = "circuitbreaker"
+	InterceptorAuth           = "auth"
+	InterceptorSignature      = "signature"
+	InterceptorLogging        = "logging"
+)
+
+// RoundTripFunc function implementing http.RoundTripper.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Interceptor intercept request before it's sent and response after it's received. Call next to continue the chain.
+// Like http.RoundTripper, it should not modify the request, clone it instead.
+type Interceptor func(req *http.Request, next RoundTripFunc) (*http.Response, error)
+
+// InterceptorSpec custom interceptor and its position in the chain.
+// If both Before and After are empty or refer to unknown name, it's placed innermost, right before Transport.
+type InterceptorSpec struct {
+	Name        string
+	Interceptor Interceptor
+
+	// Before name of interceptor this one is placed before, i.e. wrapping it.
+	Before string
+
+	// After name of interceptor this one is placed after, i.e. wrapped by it.
+	After string
+}
+
+type namedInterceptor struct {
+	name string
+	fn   Interceptor
+}
+
+// interceptors build ordered chain of built-in and custom interceptors, outermost first.
+func interceptors(opts *Opts, logger *log.Logger) []namedInterceptor {
+	list := []namedInterceptor{
+		{name: InterceptorRequestID, fn: requestID},
+		{name: InterceptorTrace, fn: tracing(opts.Tracer)},
+		{name: InterceptorMetrics},
+		{name: InterceptorCache},
+		{name: InterceptorRetry},
+		{name: InterceptorHedge},
+		{name: InterceptorRateLimit},
+		{name: InterceptorCircuitBreaker},
+		{name: InterceptorAuth},
+		{name: InterceptorSignature},
+		{name: InterceptorLogging},
+	}
+	enable := func(name string, fn Interceptor) {
+		for i := range list {
+			if list[i].name == name {
+				list[i].fn = fn
+			}
+		}
+	}
+	if opts.Metrics != nil {
+		enable(InterceptorMetrics, metrics(opts.Metrics))
+	}
+	if opts.Cache != nil {
+		cache := newHTTPCache(opts.Cache)
+		cache.authorized = opts.Auth != nil
+		enable(InterceptorCache, cache.intercept)
+	}
+	if opts.MaxRetry > 0 {
+		enable(InterceptorRetry, newRetry(opts).intercept)
+	}
+	if opts.Hedging != nil {
+		enable(InterceptorHedge, newHedger(opts.Hedging).intercept)
+	}
+	if len(opts.RateLimits) > 0 {
+		enable(InterceptorRateLimit, newRateLimiter(opts.RateLimits, opts.RateLimitStore).intercept)
+	}
+	if opts.CircuitBreaker != nil {
+		enable(InterceptorCircuitBreaker, newBreaker(circuitBreakerOpts(opts, logger)).intercept)
+	}
+	if opts.Auth != nil {
+		enable(InterceptorAuth, authorize(opts.Auth))
+	}
+	if opts.Signer != nil {
+		enable(InterceptorSignature, sign(opts.Signer))
+	}
+	if opts.EnableLogger {
+		enable(InterceptorLogging, (&logging{logger: logger}).intercept)
+	}
+
+	for _, spec := range opts.Interceptors {
+		list = insertInterceptor(list, spec)
+	}
+
+	enabled := list[:0]
+	for _, i := range list {
+		if i.fn != nil {
+			enabled = append(enabled, i)
+		}
+	}
+	return enabled
+}
+
+func insertInterceptor(list []namedInterceptor, spec InterceptorSpec) []namedInterceptor {
+	item := namedInterceptor{name: spec.Name, fn: spec.Interceptor}
+	pos := len(list)
+	for i := range list {
+		if spec.Before != "" && list[i].name == spec.Before {
+			pos = i
+			break
+		}
+		if spec.After != "" && list[i].name == spec.After {
+			pos = i + 1
+			break
+		}
+	}
+	list = append(list, namedInterceptor{})
+	copy(list[pos+1:], list[pos:])
+	list[pos] = item
+	return list
+}
+
+// chain wrap transport with interceptors, the first one is executed first.
+func chain(interceptors []namedInterceptor, transport http.RoundTripper) http.RoundTripper {
+	next := RoundTripFunc(transport.RoundTrip)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		fn, n := interceptors[i].fn, next
+		next = func(req *http.Request) (*http.Response, error) {
+			return fn(req, n)
+		}
+	}
+	return next
+}
+
+func newRetry(opts *Opts) *retry {
+	re := &retry{
+		nums:           opts.MaxRetry,
+		retry:          defaultRetry,
+		backOff:        defaultBackOff,
+		minBackOff:     defaultMinBackOff,
+		maxBackOff:     defaultMaxBackOff,
+		idempotencyKey: opts.RetryIdempotencyKey,
+	}
+	if opts.RetryPolicy != nil {
+		re.retry = opts.RetryPolicy
+	}
+	if opts.BackOffPolicy != nil {
+		re.backOff = opts.BackOffPolicy
+	}
+	if opts.MinBackOff != nil {
+		re.minBackOff = *opts.MinBackOff
+	}
+	if opts.MaxBackOff != nil {
+		re.maxBackOff = *opts.MaxBackOff
+	}
+	return re
+}
+
+// circuitBreakerOpts state changes are logged if logger enabled.
+func circuitBreakerOpts(opts *Opts, logger *log.Logger) *CircuitBreaker {
+	cb := *opts.CircuitBreaker
+	if opts.EnableLogger {
+		onStateChange := cb.OnStateChange
+		cb.OnStateChange = func(host string, from CircuitState, to CircuitState) {
+			logger.Printf("%s | httpclient | CIRCUIT | %s | %s -> %s\n", time.Now().Format(time.RFC3339), host, from, to)
+			if onStateChange != nil {
+				onStateChange(host, from, to)
+			}
+		}
+	}
+	return &cb
+}
+
+// requestID set Request-Id header if empty, e.g. request sent with Client.Do directly.
+func requestID(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+	if req.Header.Get("Request-Id") == "" {
+		req = req.Clone(req.Context())
+		if id := req.Header.Get("X-Request-Id"); id != "" {
+			req.Header.Set("Request-Id", id)
+		} else {
+			req.Header.Set("Request-Id", _uuid.New().String())
+		}
+	}
+	return next(req)
+}
+
+// tracing trace request with client span and send its context in traceparent header.
+// Retries and hedged requests are sent within the same span.
+func tracing(tracer *_trace.Tracer) Interceptor {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		t := tracer
+		if t == nil {
+			t = _trace.Default()
+		}
+		ctx, span := t.Start(req.Context(), "HTTP "+req.Method, _trace.SpanKindClient)
+		defer span.End()
+		u := *req.URL
+		u.User, u.RawQuery, u.Fragment = nil, "", ""
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.url", u.String())
+		span.SetAttribute("http.host", req.URL.Host)
+		span.SetAttribute("http.request_id", req.Header.Get("Request-Id"))
+
+		req = req.Clone(ctx)
+		_trace.Inject(ctx, req.Header)
+		resp, err := next(req)
+		if err != nil {
+			span.SetError(err)
+			return resp, err
+		}
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(_trace.StatusError, resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+// sign sign request right before it's sent, after all headers are set.
+func sign(signer *_signature.Signer) Interceptor {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		if err := signer.Sign(req); err != nil {
+			return nil, err
+		}
+		return next(req)
+	}
+}
+
+func metrics(fn func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)) Interceptor {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		fn(req, resp, err, time.Since(start))
+		return resp, err
+	}
+}
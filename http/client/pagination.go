@@ -0,0 +1,597 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	_codec "github.com/mfathirirhas/godevkit/http/codec"
+)
+
+const (
+	defaultCursorParam = "cursor"
+	defaultOffsetParam = "offset"
+	defaultLimitParam  = "limit"
+	defaultPageLimit   = 100
+)
+
+// Paginator build request of the next page.
+type Paginator interface {
+	// Next return request of the page after page, nil if page is the last one.
+	Next(page *Page) (*Request, error)
+}
+
+// indexedPaginator paginator whose requests are known upfront, so pages can be fetched concurrently.
+type indexedPaginator interface {
+	Paginator
+	at(req *Request, index int) *Request
+}
+
+// LinkPagination follow URL of Link header with rel="next", RFC 5988. Pagination ends with page without it.
+type LinkPagination struct{}
+
+// CursorPagination send cursor found in page body as query parameter of the next page.
+type CursorPagination struct {
+	// Path of cursor in JSON body, keys separated by dot, e.g. meta.next_cursor.
+	// Pagination ends with page whose cursor is missing, null or empty.
+	Path string
+	// Param query parameter of cursor. Default is cursor.
+	Param string
+}
+
+// OffsetPagination request pages with offset and limit query parameters. Pagination ends with page having less than Limit items.
+type OffsetPagination struct {
+	// OffsetParam default is offset.
+	OffsetParam string
+	// LimitParam default is limit.
+	LimitParam string
+	// Limit items per page. Default is 100.
+	Limit int
+	// Start offset of the first page.
+	Start int
+}
+
+// PageOpts options of Pages and Items.
+type PageOpts struct {
+	// Method default is GET. Body of the first request is sent with each page, so it can't be io.Reader.
+	Method string
+
+	// Paginator default is LinkPagination.
+	Paginator Paginator
+
+	// Items path of items array in JSON body, keys separated by dot, e.g. data.items. Empty means body is the array.
+	Items string
+
+	// MaxPages optional, maximum pages requested.
+	MaxPages int
+	// MaxItems optional, maximum items returned by Items.
+	MaxItems int
+
+	// Interval optional, minimum wait between page requests.
+	Interval time.Duration
+
+	// Concurrency pages fetched at once. Only for OffsetPagination, other pages depend on the previous one. Default is 1.
+	Concurrency int
+}
+
+// Page response of a page.
+type Page struct {
+	*Response
+	// Index of the page, starts from 0.
+	Index int
+	// Request of the page.
+	Request *Request
+
+	itemsPath string
+	items     []json.RawMessage
+	parsed    bool
+}
+
+// Len return number of items in the page.
+func (p *Page) Len() (int, error) {
+	items, err := p.rawItems()
+	return len(items), err
+}
+
+// ScanItems decode items of the page into destination, e.g. pointer to slice.
+func (p *Page) ScanItems(destination interface{}) error {
+	if p.itemsPath == "" {
+		return p.Response.Scan(destination)
+	}
+	if p.Error != nil {
+		return p.Error
+	}
+	raw, err := jsonPath(p.Body, p.itemsPath)
+	if err != nil || raw == nil {
+		return err
+	}
+	return jsonResponse(p.Response, raw).Scan(destination)
+}
+
+func (p *Page) rawItems() ([]json.RawMessage, error) {
+	if p.parsed {
+		return p.items, nil
+	}
+	if p.Error != nil {
+		return nil, p.Error
+	}
+	raw, err := jsonPath(p.Body, p.itemsPath)
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		if err := json.Unmarshal(raw, &p.items); err != nil {
+			return nil, err
+		}
+	}
+	p.parsed = true
+	return p.items, nil
+}
+
+// PageIterator iterate pages lazily, each page is requested on Next.
+type PageIterator struct {
+	c      *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	opts   PageOpts
+
+	first   *Request
+	next    *Request
+	page    *Page
+	end     bool
+	err     error
+	nextErr error
+
+	requested int
+	lastSent  time.Time
+	ahead     []*pageFetch
+}
+
+type pageFetch struct {
+	page   chan *Page
+	cancel context.CancelFunc
+}
+
+// Pages return iterator of pages starting from req. Iteration stops when ctx is done or PageIterator is closed.
+func (c *Client) Pages(ctx context.Context, req *Request, opts *PageOpts) *PageIterator {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts == nil {
+		opts = &PageOpts{}
+	}
+	it := &PageIterator{c: c, opts: *opts}
+	it.ctx, it.cancel = context.WithCancel(ctx)
+	if it.opts.Paginator == nil {
+		it.opts.Paginator = LinkPagination{}
+	}
+	if req == nil {
+		it.fail(ErrRequestNil)
+		return it
+	}
+	it.first = req.clone()
+	if p, ok := it.opts.Paginator.(indexedPaginator); ok {
+		it.first = p.at(req, 0)
+	}
+	it.next = it.first
+	return it
+}
+
+// Next request the next page, false if there are no more pages or request failed, see Err.
+func (it *PageIterator) Next() bool {
+	if it.err != nil || it.end {
+		return false
+	}
+	if it.nextErr != nil {
+		it.fail(it.nextErr)
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.fail(err)
+		return false
+	}
+	index := 0
+	if it.page != nil {
+		index = it.page.Index + 1
+	}
+	if it.opts.MaxPages > 0 && index >= it.opts.MaxPages {
+		it.finish()
+		return false
+	}
+
+	var page *Page
+	if p, ok := it.opts.Paginator.(indexedPaginator); ok && it.opts.Concurrency > 1 {
+		page = it.fetchAhead(p)
+	} else {
+		if err := it.wait(); err != nil {
+			it.fail(err)
+			return false
+		}
+		page = it.fetch(it.ctx, it.next, index)
+	}
+	if page == nil {
+		it.fail(it.ctx.Err())
+		return false
+	}
+	if err := pageError(page); err != nil {
+		it.fail(err)
+		return false
+	}
+
+	it.page = page
+	it.next, it.nextErr = it.opts.Paginator.Next(page)
+	if it.next == nil && it.nextErr == nil {
+		it.finish()
+		it.end = true
+	}
+	return true
+}
+
+// Page return the current page.
+func (it *PageIterator) Page() *Page {
+	return it.page
+}
+
+// Err return error which stopped iteration, nil if iteration ended normally.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// Close stop iteration and cancel pages fetched ahead.
+func (it *PageIterator) Close() error {
+	it.finish()
+	it.end = true
+	return nil
+}
+
+// fetchAhead keep up to Concurrency pages requested ahead and return the first of them.
+func (it *PageIterator) fetchAhead(p indexedPaginator) *Page {
+	for len(it.ahead) < it.opts.Concurrency && (it.opts.MaxPages <= 0 || it.requested < it.opts.MaxPages) {
+		if len(it.ahead) > 0 && it.opts.Interval > 0 && time.Since(it.lastSent) < it.opts.Interval {
+			break
+		}
+		req, index := p.at(it.first, it.requested), it.requested
+		if err := it.wait(); err != nil {
+			return nil
+		}
+		ctx, cancel := context.WithCancel(it.ctx)
+		f := &pageFetch{page: make(chan *Page, 1), cancel: cancel}
+		go func() {
+			f.page <- it.fetch(ctx, req, index)
+		}()
+		it.ahead = append(it.ahead, f)
+	}
+
+	f := it.ahead[0]
+	it.ahead = it.ahead[1:]
+	select {
+	case page := <-f.page:
+		f.cancel()
+		return page
+	case <-it.ctx.Done():
+		f.cancel()
+		return nil
+	}
+}
+
+func (it *PageIterator) fetch(ctx context.Context, req *Request, index int) *Page {
+	resp := it.c.method(ctx, it.opts.Method, req)
+	return &Page{Response: resp, Index: index, Request: req, itemsPath: it.opts.Items}
+}
+
+// wait until Interval has passed since the last page request.
+func (it *PageIterator) wait() error {
+	if it.opts.Interval > 0 && !it.lastSent.IsZero() {
+		if d := it.opts.Interval - time.Since(it.lastSent); d > 0 {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-it.ctx.Done():
+				return it.ctx.Err()
+			}
+		}
+	}
+	it.lastSent = time.Now()
+	it.requested++
+	return nil
+}
+
+func (it *PageIterator) fail(err error) {
+	it.err = err
+	it.finish()
+}
+
+// finish cancel pages fetched ahead and release context.
+func (it *PageIterator) finish() {
+	for _, f := range it.ahead {
+		f.cancel()
+	}
+	it.ahead = nil
+	it.cancel()
+}
+
+func pageError(page *Page) error {
+	if page.Error != nil {
+		return page.Error
+	}
+	if page.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s", ErrUnexpectedStatus, page.Status)
+	}
+	return nil
+}
+
+// ItemIterator iterate items of pages lazily, the next page is requested once items of the current one are consumed.
+type ItemIterator struct {
+	ctx      context.Context
+	pages    *PageIterator
+	maxItems int
+
+	items []json.RawMessage
+	i     int
+	n     int
+	item  json.RawMessage
+	err   error
+}
+
+// Items return iterator of items of pages starting from req. Items are found in JSON body at opts.Items.
+func (c *Client) Items(ctx context.Context, req *Request, opts *PageOpts) *ItemIterator {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	it := &ItemIterator{ctx: ctx, pages: c.Pages(ctx, req, opts)}
+	if opts != nil {
+		it.maxItems = opts.MaxItems
+	}
+	return it
+}
+
+// Next move to the next item, false if there are no more items or request failed, see Err.
+func (it *ItemIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		it.pages.Close()
+		return false
+	}
+	if it.maxItems > 0 && it.n >= it.maxItems {
+		it.pages.Close()
+		return false
+	}
+	for it.i >= len(it.items) {
+		if !it.pages.Next() {
+			return false
+		}
+		items, err := it.pages.Page().rawItems()
+		if err != nil {
+			it.err = err
+			it.pages.Close()
+			return false
+		}
+		it.items, it.i = items, 0
+	}
+	it.item = it.items[it.i]
+	it.i++
+	it.n++
+	return true
+}
+
+// Scan decode the current item into destination.
+func (it *ItemIterator) Scan(destination interface{}) error {
+	return jsonResponse(it.pages.Page().Response, it.item).Scan(destination)
+}
+
+// Page return page of the current item.
+func (it *ItemIterator) Page() *Page {
+	return it.pages.Page()
+}
+
+// Err return error which stopped iteration, nil if iteration ended normally.
+func (it *ItemIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.pages.Err()
+}
+
+// Close stop iteration.
+func (it *ItemIterator) Close() error {
+	return it.pages.Close()
+}
+
+func (LinkPagination) Next(page *Page) (*Request, error) {
+	link := nextLink(page.Header)
+	if link == "" {
+		return nil, nil
+	}
+	current, err := page.Request.URLQuery()
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	req := page.Request.clone()
+	req.BaseURL = base.ResolveReference(ref).String()
+	req.URLValues = nil
+	return req, nil
+}
+
+func (p CursorPagination) Next(page *Page) (*Request, error) {
+	raw, err := jsonPath(page.Body, p.Path)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	cursor := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &cursor); err != nil {
+			return nil, err
+		}
+	}
+	if cursor == "" {
+		return nil, nil
+	}
+	param := p.Param
+	if param == "" {
+		param = defaultCursorParam
+	}
+	req := page.Request.clone()
+	req.URLValues.Set(param, cursor)
+	return req, nil
+}
+
+func (p OffsetPagination) Next(page *Page) (*Request, error) {
+	n, err := page.Len()
+	if err != nil {
+		return nil, err
+	}
+	if n < p.limit() {
+		return nil, nil
+	}
+	return p.at(page.Request, page.Index+1), nil
+}
+
+func (p OffsetPagination) at(req *Request, index int) *Request {
+	offsetParam, limitParam := p.OffsetParam, p.LimitParam
+	if offsetParam == "" {
+		offsetParam = defaultOffsetParam
+	}
+	if limitParam == "" {
+		limitParam = defaultLimitParam
+	}
+	r := req.clone()
+	r.URLValues.Set(offsetParam, strconv.Itoa(p.Start+index*p.limit()))
+	r.URLValues.Set(limitParam, strconv.Itoa(p.limit()))
+	return r
+}
+
+func (p OffsetPagination) limit() int {
+	if p.Limit <= 0 {
+		return defaultPageLimit
+	}
+	return p.Limit
+}
+
+// clone copy request for another page, with its own header, query and request id.
+func (r *Request) clone() *Request {
+	c := *r
+	c.Header = r.Header.Clone()
+	c.URLValues = make(url.Values, len(r.URLValues))
+	for k, v := range r.URLValues {
+		c.URLValues[k] = append([]string(nil), v...)
+	}
+	c.RequestID = ""
+	return &c
+}
+
+// jsonPath return value at path of JSON data, keys separated by dot and array elements by index, e.g. data.items.0.id.
+// Nil if it's missing or null.
+func jsonPath(data []byte, path string) (json.RawMessage, error) {
+	raw := json.RawMessage(bytes.TrimSpace(data))
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			if len(raw) == 0 {
+				return nil, nil
+			}
+			switch raw[0] {
+			case '{':
+				var obj map[string]json.RawMessage
+				if err := json.Unmarshal(raw, &obj); err != nil {
+					return nil, err
+				}
+				raw = obj[key]
+			case '[':
+				i, err := strconv.Atoi(key)
+				if err != nil {
+					return nil, nil
+				}
+				var arr []json.RawMessage
+				if err := json.Unmarshal(raw, &arr); err != nil {
+					return nil, err
+				}
+				if i < 0 || i >= len(arr) {
+					return nil, nil
+				}
+				raw = arr[i]
+			default:
+				return nil, nil
+			}
+			raw = bytes.TrimSpace(raw)
+		}
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	return raw, nil
+}
+
+// jsonResponse return resp with JSON body, to decode part of its body with Response.Scan.
+func jsonResponse(resp *Response, body []byte) *Response {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Type", _codec.MIMEJSON)
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     header,
+		Body:       body,
+	}
+}
+
+// nextLink return URL of Link header with rel="next", empty if none.
+func nextLink(header http.Header) string {
+	for _, v := range header.Values("Link") {
+		for v != "" {
+			start := strings.IndexByte(v, '<')
+			end := strings.IndexByte(v, '>')
+			if start < 0 || end < start {
+				break
+			}
+			link := v[start+1 : end]
+			v = v[end+1:]
+
+			// params end at comma outside quotes.
+			quoted, i := false, 0
+			for ; i < len(v); i++ {
+				if v[i] == '"' {
+					quoted = !quoted
+				} else if v[i] == ',' && !quoted {
+					break
+				}
+			}
+			params := v[:i]
+			if i < len(v) {
+				v = v[i+1:]
+			} else {
+				v = ""
+			}
+			for _, param := range strings.Split(params, ";") {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(kv[1]), `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return link
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRateLimited = errors.New("httpclient: rate limit exceeded")
+)
+
+// RateLimit limit of requests matching Host and Path. Each host has its own quota.
+type RateLimit struct {
+	// Host of request, e.g. api.example.com. Empty matches any host.
+	Host string
+
+	// Path optional pattern of request path in path.Match syntax, e.g. /v1/users/*. Empty matches any path.
+	Path string
+
+	// Rate maximum requests per Per. Zero means no rate limit.
+	Rate int
+	// Per default is 1 second.
+	Per time.Duration
+	// Burst maximum requests sent at once. Default is Rate. Ignored in distributed mode.
+	Burst int
+
+	// MaxConcurrent maximum in-flight requests, a request is in-flight until its response body is closed.
+	// Zero means no limit. Always local to this client, even in distributed mode.
+	MaxConcurrent int
+
+	// FailFast if true then return ErrRateLimited instead of waiting when limit is reached.
+	// Otherwise wait as long as request context allows, fail fast if the wait would exceed its deadline.
+	FailFast bool
+}
+
+// RateLimitStore shared fixed window counters for distributed rate limit, so all replicas share the quota, e.g. *redis.Redis.
+type RateLimitStore interface {
+	// IncrementWithin increment fixed window counter of key unless it reached limit, window starts when it's created.
+	// Return whether it's incremented and remaining ttl of window.
+	IncrementWithin(key string, limit int64, window time.Duration) (bool, time.Duration, error)
+}
+
+type rateLimiter struct {
+	rules  []RateLimit
+	store  RateLimitStore
+	mu     sync.Mutex
+	limits map[string]*limiter
+}
+
+type limiter struct {
+	rule   *RateLimit
+	key    string
+	bucket *tokenBucket
+	sem    chan struct{}
+}
+
+func newRateLimiter(rules []RateLimit, store RateLimitStore) *rateLimiter {
+	rs := make([]RateLimit, len(rules))
+	copy(rs, rules)
+	for i := range rs {
+		if rs[i].Per <= 0 {
+			rs[i].Per = time.Second
+		}
+		if rs[i].Burst <= 0 {
+			rs[i].Burst = rs[i].Rate
+		}
+	}
+	return &rateLimiter{
+		rules:  rs,
+		store:  store,
+		limits: make(map[string]*limiter),
+	}
+}
+
+func (rl *rateLimiter) intercept(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+	l := rl.limiter(req)
+	if l == nil {
+		return next(req)
+	}
+	if err := rl.wait(req.Context(), l); err != nil {
+		return nil, err
+	}
+	if l.sem == nil {
+		return next(req)
+	}
+	if err := l.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := next(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		l.release()
+		return resp, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: l.release}
+	return resp, nil
+}
+
+// limiter return limiter of the first matching rule for request host, nil if none matched.
+func (rl *rateLimiter) limiter(req *http.Request) *limiter {
+	for i := range rl.rules {
+		rule := &rl.rules[i]
+		if rule.Host != "" && rule.Host != req.URL.Host && rule.Host != req.URL.Hostname() {
+			continue
+		}
+		if rule.Path != "" {
+			if ok, _ := path.Match(rule.Path, req.URL.Path); !ok {
+				continue
+			}
+		}
+		key := fmt.Sprintf("%d:%s", i, req.URL.Host)
+		rl.mu.Lock()
+		l, ok := rl.limits[key]
+		if !ok {
+			l = &limiter{rule: rule, key: fmt.Sprintf("httpclient:ratelimit:%s:%s", req.URL.Host, rule.Path)}
+			if rule.Rate > 0 {
+				l.bucket = newTokenBucket(rule.Rate, rule.Per, rule.Burst)
+			}
+			if rule.MaxConcurrent > 0 {
+				l.sem = make(chan struct{}, rule.MaxConcurrent)
+			}
+			rl.limits[key] = l
+		}
+		rl.mu.Unlock()
+		return l
+	}
+	return nil
+}
+
+// wait until request is allowed by rate limit.
+func (rl *rateLimiter) wait(ctx context.Context, l *limiter) error {
+	if l.rule.Rate <= 0 {
+		return nil
+	}
+	for {
+		var (
+			delay   time.Duration
+			allowed bool
+		)
+		if rl.store != nil {
+			var err error
+			allowed, delay, err = rl.store.IncrementWithin(l.key, int64(l.rule.Rate), l.rule.Per)
+			if err != nil {
+				return err
+			}
+			if delay <= 0 {
+				// window without expiry or just expired, wait a whole window rather than retrying right away.
+				delay = l.rule.Per
+			}
+		} else {
+			allowed, delay = l.bucket.take(time.Now())
+		}
+		if allowed {
+			return nil
+		}
+		if l.rule.FailFast {
+			return ErrRateLimited
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return ErrRateLimited
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (l *limiter) acquire(ctx context.Context) error {
+	if l.rule.FailFast {
+		select {
+		case l.sem <- struct{}{}:
+			return nil
+		default:
+			return ErrRateLimited
+		}
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	<-l.sem
+}
+
+// releaseBody release concurrency slot once response body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	burst  float64
+	// interval between tokens.
+	interval time.Duration
+	last     time.Time
+}
+
+func newTokenBucket(rate int, per time.Duration, burst int) *tokenBucket {
+	return &tokenBucket{
+		tokens:   float64(burst),
+		burst:    float64(burst),
+		interval: per / time.Duration(rate),
+		last:     time.Now(),
+	}
+}
+
+// take a token if available, otherwise return how long until the next one.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(b.interval)
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(b.interval))
+}
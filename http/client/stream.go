@@ -0,0 +1,304 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDownloadResumes  = 3
+	downloadPartSuffix      = ".part"
+	downloadValidatorSuffix = ".validator"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("httpclient: checksum mismatch")
+	ErrUnexpectedStatus = errors.New("httpclient: unexpected status")
+)
+
+// ProgressFunc called with bytes transferred so far and total bytes, total is -1 if unknown.
+type ProgressFunc func(transferred int64, total int64)
+
+// StreamResponse response which body is not read yet. Body must be closed.
+type StreamResponse struct {
+	StatusCode    int
+	Status        string
+	Header        http.Header
+	ContentLength int64
+	Body          io.ReadCloser
+	Error         error
+}
+
+// Close close response body.
+func (r *StreamResponse) Close() error {
+	if r.Body == nil {
+		return nil
+	}
+	return r.Body.Close()
+}
+
+// Err return response error.
+func (r *StreamResponse) Err() error {
+	return r.Error
+}
+
+// Stream send request and return response without reading its body, for large responses.
+// Request body is encoded as req.ContentType, if it's io.Reader then it's streamed as is.
+func (c *Client) Stream(ctx context.Context, method string, req *Request) *StreamResponse {
+	if req == nil {
+		return &StreamResponse{Error: ErrRequestNil}
+	}
+	var body io.Reader
+	if req.Body != nil {
+		b, err := req.Encode(req.ContentType)
+		if err != nil {
+			return &StreamResponse{Error: err}
+		}
+		body = b
+	}
+	resp, err := c.send(ctx, method, req, body)
+	if err != nil {
+		return &StreamResponse{Error: err}
+	}
+	return &StreamResponse{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		Body:          trackDownload(resp, req.DownloadProgress, 0),
+	}
+}
+
+// DownloadOpts options of Download.
+type DownloadOpts struct {
+	// Checksum optional, expected hex encoded digest of the file.
+	Checksum string
+	// Hash of Checksum. Default is sha256.
+	Hash func() hash.Hash
+
+	// MaxResumes maximum resumptions of interrupted download within one call. Default is 3, negative means never.
+	MaxResumes int
+
+	// Progress optional, called as file is received. Resumed bytes are counted as transferred.
+	Progress ProgressFunc
+}
+
+// Download GET req into file at path, written atomically through path.part which is renamed once download completes.
+// Interrupted download is resumed with Range request, including path.part left by previous call, which is sent
+// with If-Range of ETag or Last-Modified of the file, kept in path.part.validator, so changed file is started over.
+// Download without validator is started over as well. path.part is removed if nothing is written,
+// e.g. on unexpected status, and if checksum doesn't match, in which case ErrChecksumMismatch is returned.
+func (c *Client) Download(ctx context.Context, req *Request, path string, opts *DownloadOpts) (int64, error) {
+	if req == nil {
+		return 0, ErrRequestNil
+	}
+	if err := req.init(); err != nil {
+		return 0, err
+	}
+	if opts == nil {
+		opts = &DownloadOpts{}
+	}
+	maxResumes := opts.MaxResumes
+	if maxResumes == 0 {
+		maxResumes = defaultDownloadResumes
+	}
+
+	part := path + downloadPartSuffix
+	validatorFile := part + downloadValidatorSuffix
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	// remove left overs once nothing is written.
+	removePart := func() {
+		f.Close()
+		os.Remove(part)
+		os.Remove(validatorFile)
+	}
+
+	var h hash.Hash
+	if opts.Checksum != "" {
+		if opts.Hash != nil {
+			h = opts.Hash()
+		} else {
+			h = sha256.New()
+		}
+	}
+	// resume from what's written already.
+	offset, err := io.Copy(hashWriter(h), f)
+	if err != nil {
+		return 0, err
+	}
+
+	var validator string
+	if offset > 0 {
+		b, _ := ioutil.ReadFile(validatorFile)
+		validator = string(b)
+	}
+	for attempt := 0; ; attempt++ {
+		n, v, err := c.downloadRange(ctx, req, f, h, offset, validator, opts.Progress)
+		offset += n
+		if v != validator {
+			validator = v
+			if v == "" {
+				os.Remove(validatorFile)
+			} else if wErr := ioutil.WriteFile(validatorFile, []byte(v), 0644); wErr != nil && err == nil {
+				err = wErr
+			}
+		}
+		if err == nil {
+			break
+		}
+		if errors.Is(err, ErrUnexpectedStatus) || ctx != nil && ctx.Err() != nil || maxResumes < 0 || attempt >= maxResumes {
+			if offset == 0 {
+				removePart()
+			}
+			return offset, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return offset, err
+	}
+	if err := f.Close(); err != nil {
+		return offset, err
+	}
+	if h != nil && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), opts.Checksum) {
+		removePart()
+		return offset, ErrChecksumMismatch
+	}
+	os.Remove(validatorFile)
+	return offset, os.Rename(part, path)
+}
+
+// downloadRange download from offset into f, restarting from scratch if server doesn't honour the range
+// or validator is unknown, as there's no telling whether the file is still the same.
+// Return bytes written after offset, which can be negative if file is truncated, and validator of the file,
+// the given one if the file is not received.
+func (c *Client) downloadRange(ctx context.Context, req *Request, f *os.File, h hash.Hash, offset int64, validator string, progress ProgressFunc) (int64, string, error) {
+	r := *req
+	r.Header = req.Header.Clone()
+	r.DownloadProgress = nil
+	if offset > 0 && validator != "" {
+		r.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		r.Header.Set("If-Range", validator)
+	}
+	resp, err := c.send(ctx, http.MethodGet, &r, nil)
+	if err != nil {
+		return 0, validator, err
+	}
+	defer resp.Body.Close()
+
+	written := int64(0)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) == offset:
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent || (resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0):
+		// range ignored or file changed, start over.
+		if err := f.Truncate(0); err != nil {
+			return 0, "", err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, "", err
+		}
+		if h != nil {
+			h.Reset()
+		}
+		written, offset = -offset, 0
+		if resp.StatusCode != http.StatusOK {
+			drain(resp)
+			n, v, err := c.downloadRange(ctx, req, f, h, 0, "", progress)
+			return written + n, v, err
+		}
+	default:
+		return 0, validator, fmt.Errorf("%w: %s", ErrUnexpectedStatus, resp.Status)
+	}
+
+	v := resp.Header.Get("ETag")
+	if v == "" || strings.HasPrefix(v, "W/") {
+		v = resp.Header.Get("Last-Modified")
+	}
+	n, err := io.Copy(io.MultiWriter(f, hashWriter(h)), trackDownload(resp, progress, offset))
+	return written + n, v, err
+}
+
+// contentRangeStart return start of Content-Range, e.g. 100 of "bytes 100-199/200". -1 if invalid.
+func contentRangeStart(v string) int64 {
+	v = strings.TrimPrefix(v, "bytes ")
+	i := strings.IndexByte(v, '-')
+	if i < 0 {
+		return -1
+	}
+	start, err := strconv.ParseInt(v[:i], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+func hashWriter(h hash.Hash) io.Writer {
+	if h == nil {
+		return ioutil.Discard
+	}
+	return h
+}
+
+// progressReader report bytes read to fn.
+type progressReader struct {
+	io.ReadCloser
+	n     int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.fn(p.n, p.total)
+	}
+	return n, err
+}
+
+// trackUpload report progress of request body, including bodies replayed by GetBody.
+func trackUpload(req *http.Request, fn ProgressFunc) {
+	total := req.ContentLength
+	if total == 0 {
+		total = -1
+	}
+	req.Body = &progressReader{ReadCloser: req.Body, total: total, fn: fn}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &progressReader{ReadCloser: body, total: total, fn: fn}, nil
+		}
+	}
+}
+
+// trackDownload return response body reporting progress to fn, counting from offset.
+func trackDownload(resp *http.Response, fn ProgressFunc, offset int64) io.ReadCloser {
+	if fn == nil {
+		return resp.Body
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	return &progressReader{ReadCloser: resp.Body, n: offset, total: total, fn: fn}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxIdleConns        = 100
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+var (
+	ErrNoCertificate = errors.New("httpclient: no certificate found in PEM")
+)
+
+// TLSOpts TLS options of client transport.
+type TLSOpts struct {
+	// RootCAs optional, CAs to verify servers with. Default is system CAs, see LoadRootCAs.
+	RootCAs *x509.CertPool
+
+	// Certificates optional, client certificates for mTLS, e.g. loaded with tls.LoadX509KeyPair.
+	Certificates []tls.Certificate
+	// GetClientCertificate optional, return client certificate on each handshake, e.g. to rotate certificates. Overrides Certificates.
+	GetClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+
+	// MinVersion minimum TLS version, e.g. tls.VersionTLS13. Default is TLS 1.2.
+	MinVersion uint16
+
+	// ServerName optional, override SNI and name verified in server certificate, e.g. when connecting by IP.
+	ServerName string
+
+	// InsecureSkipVerify skip verification of server certificate. Only for testing.
+	InsecureSkipVerify bool
+}
+
+// LoadRootCAs return system CAs along with CAs of PEM files.
+func LoadRootCAs(files ...string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, ErrNoCertificate
+		}
+	}
+	return pool, nil
+}
+
+// NewTransport create transport configured by opts, the one used by New if opts.Transport is nil.
+// Each transport has its own connection pool, http.DefaultTransport is never modified.
+func NewTransport(opts *Opts) *http.Transport {
+	dialTimeout := opts.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: defaultKeepAlive,
+	}
+
+	tr := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          defaultMaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       defaultIdleConnTimeout,
+		TLSHandshakeTimeout:   defaultTLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     !opts.DisableHTTP2,
+	}
+	if opts.MaxIdleConns > 0 {
+		tr.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	if opts.TLSHandshakeTimeout > 0 {
+		tr.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	if opts.NoProxy {
+		tr.Proxy = nil
+	} else if opts.Proxy != nil {
+		tr.Proxy = http.ProxyURL(opts.Proxy)
+	}
+	if opts.DisableHTTP2 {
+		// non-nil empty map disables HTTP/2.
+		tr.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	tr.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if t := opts.TLS; t != nil {
+		tr.TLSClientConfig.RootCAs = t.RootCAs
+		tr.TLSClientConfig.Certificates = t.Certificates
+		tr.TLSClientConfig.GetClientCertificate = t.GetClientCertificate
+		tr.TLSClientConfig.ServerName = t.ServerName
+		tr.TLSClientConfig.InsecureSkipVerify = t.InsecureSkipVerify
+		if t.MinVersion > 0 {
+			tr.TLSClientConfig.MinVersion = t.MinVersion
+		}
+	}
+	return tr
+}
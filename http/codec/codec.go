@@ -0,0 +1,250 @@
+package codec
+
+/*
+	Codecs for http body, shared by http/server and http/client.
+	Built-in: JSON, XML, MessagePack and Protobuf. Custom codecs can be added with Register.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	_msgpack "github.com/vmihailenco/msgpack/v5"
+	_protojson "google.golang.org/protobuf/encoding/protojson"
+	_proto "google.golang.org/protobuf/proto"
+)
+
+const (
+	MIMEJSON        = "application/json"
+	MIMEXML         = "application/xml"
+	MIMEMessagePack = "application/msgpack"
+	MIMEProtobuf    = "application/x-protobuf"
+)
+
+var (
+	ErrNotProtoMessage = errors.New("codec: value is not proto.Message")
+
+	mu       sync.RWMutex
+	registry = map[string]Codec{}
+	// order of registration, used when Accept header has wildcards.
+	order []string
+)
+
+// Codec marshal and unmarshal body of a content type.
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Supporter optionally implemented by Codec which can only marshal some values, e.g. protobuf only marshal proto.Message.
+type Supporter interface {
+	Supports(v interface{}) bool
+}
+
+func init() {
+	Register(JSON{})
+	Register(XML{}, "text/xml")
+	Register(MessagePack{}, "application/x-msgpack", "application/vnd.msgpack")
+	Register(Protobuf{}, "application/protobuf", "application/vnd.google.protobuf")
+}
+
+// Register add or replace codec for its content type and aliases.
+func Register(c Codec, aliases ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, ct := range append([]string{c.ContentType()}, aliases...) {
+		ct = strings.ToLower(ct)
+		if _, ok := registry[ct]; !ok {
+			order = append(order, ct)
+		}
+		registry[ct] = c
+	}
+}
+
+// Get return codec for content type. Parameters such as charset are ignored.
+func Get(contentType string) (Codec, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := registry[mt]
+	return c, ok
+}
+
+// Default return JSON codec.
+func Default() Codec {
+	return JSON{}
+}
+
+// Negotiate choose codec from Accept header which able to marshal v, ordered by quality value.
+// Return false if nothing acceptable.
+func Negotiate(accept string, v interface{}) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return Default(), true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, mt := range parseAccept(accept) {
+		if mt == "*/*" {
+			if supports(Default(), v) {
+				return Default(), true
+			}
+		}
+		for _, ct := range order {
+			if !matchMediaType(mt, ct) {
+				continue
+			}
+			if c := registry[ct]; supports(c, v) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func supports(c Codec, v interface{}) bool {
+	if s, ok := c.(Supporter); ok {
+		return s.Supports(v)
+	}
+	return true
+}
+
+func matchMediaType(pattern, contentType string) bool {
+	if pattern == "*/*" || pattern == contentType {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
+
+// parseAccept return media types from Accept header sorted by quality value, media types with q=0 excluded.
+func parseAccept(accept string) []string {
+	type mediaType struct {
+		name string
+		q    float64
+	}
+	var mts []mediaType
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qv, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(qv, 64); err == nil {
+				q = f
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		mts = append(mts, mediaType{name: mt, q: q})
+	}
+	sort.SliceStable(mts, func(i, j int) bool {
+		return mts[i].q > mts[j].q
+	})
+	names := make([]string, len(mts))
+	for i := range mts {
+		names[i] = mts[i].name
+	}
+	return names
+}
+
+// JSON codec. proto.Message is marshalled using protojson.
+type JSON struct{}
+
+func (JSON) ContentType() string {
+	return MIMEJSON
+}
+
+func (JSON) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(_proto.Message); ok {
+		return _protojson.Marshal(m)
+	}
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(_proto.Message); ok {
+		return _protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// XML codec.
+type XML struct{}
+
+func (XML) ContentType() string {
+	return MIMEXML
+}
+
+func (XML) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XML) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// MessagePack codec. Uses `msgpack` struct tags, fallback to `json` struct tags.
+type MessagePack struct{}
+
+func (MessagePack) ContentType() string {
+	return MIMEMessagePack
+}
+
+func (MessagePack) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := _msgpack.NewEncoder(&b)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (MessagePack) Unmarshal(data []byte, v interface{}) error {
+	dec := _msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// Protobuf codec. Only support proto.Message.
+type Protobuf struct{}
+
+func (Protobuf) ContentType() string {
+	return MIMEProtobuf
+}
+
+func (Protobuf) Supports(v interface{}) bool {
+	_, ok := v.(_proto.Message)
+	return ok
+}
+
+func (Protobuf) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(_proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return _proto.Marshal(m)
+}
+
+func (Protobuf) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(_proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return _proto.Unmarshal(data, m)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+
+	_log "github.com/mfathirirhas/godevkit/log"
+)
+
+// AdminOpts options for admin server.
+// Admin server exposes route table, pprof, expvar, build info and log level toggle. Do not expose it publicly.
+type AdminOpts struct {
+	Port uint16
+
+	// Version and Commit of the running build, e.g. injected with -ldflags. Version fallback to main module version if empty.
+	Version string
+	Commit  string
+}
+
+// BuildInfo build information served by admin server.
+type BuildInfo struct {
+	Path      string `json:"path"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+func (s *Server) newAdmin(opts *AdminOpts) *http.Server {
+	info := BuildInfo{
+		Version:   opts.Version,
+		Commit:    opts.Commit,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Path = bi.Main.Path
+		if info.Version == "" {
+			info.Version = bi.Main.Version
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			ResponseString(w, r, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		ResponseJSON(w, r, http.StatusOK, s.Routes())
+	})
+	mux.HandleFunc("/buildinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			ResponseString(w, r, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		ResponseJSON(w, r, http.StatusOK, info)
+	})
+	mux.HandleFunc("/loglevel", logLevel)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", opts.Port),
+		Handler: mux,
+	}
+}
+
+// logLevel GET return current level of godevkit/log, PUT or POST with `level` param set it, e.g. PUT /loglevel?level=debug.
+func logLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		if err := _log.SetLevel(r.FormValue("level")); err != nil {
+			ResponseString(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		ResponseString(w, r, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	ResponseJSON(w, r, http.StatusOK, map[string]string{"level": _log.GetLevel()})
+}
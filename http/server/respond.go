@@ -0,0 +1,54 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	_codec "github.com/mfathirirhas/godevkit/http/codec"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("httpserver: unsupported request content type")
+)
+
+// RegisterCodec register custom codec used by Respond and Decode, along with its content type aliases.
+func RegisterCodec(c _codec.Codec, aliases ...string) {
+	_codec.Register(c, aliases...)
+}
+
+// Respond response by encoding body with codec negotiated from request Accept header.
+// Built-in JSON, XML, MessagePack and Protobuf(only if body is proto.Message).
+// If nothing acceptable then JSON is used.
+// Call at the end line of your handler.
+func Respond(w http.ResponseWriter, r *http.Request, statusCode int, body interface{}) error {
+	c, ok := _codec.Negotiate(r.Header.Get("Accept"), body)
+	if !ok {
+		c = _codec.Default()
+	}
+	b, err := c.Marshal(body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", c.ContentType())
+	w.Header().Add("Vary", "Accept")
+	Response(w, r, statusCode, b)
+	return nil
+}
+
+// Decode decode request body into v based on request Content-Type. JSON is used if Content-Type is empty.
+// Return ErrUnsupportedMediaType if no codec registered for the Content-Type.
+func Decode(r *http.Request, v interface{}) error {
+	c := _codec.Default()
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var ok bool
+		if c, ok = _codec.Get(ct); !ok {
+			return ErrUnsupportedMediaType
+		}
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(b, v)
+}
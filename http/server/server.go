@@ -1,17 +1,22 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"reflect"
+	"runtime"
 	"runtime/debug"
 	"strconv"
+	"sync"
 	"time"
 
 	_uuid "github.com/google/uuid"
 	_router "github.com/julienschmidt/httprouter"
+	_trace "github.com/mfathirirhas/godevkit/trace"
 	_cors "github.com/rs/cors"
 )
 
@@ -25,6 +30,27 @@ type Server struct {
 	enableLogger bool
 	logger       *log.Logger
 	cors         *_cors.Cors
+	middlewares  []namedMiddleware
+	routesMu     sync.RWMutex
+	routes       []Route
+	admin        *http.Server
+	tracer       *_trace.Tracer
+}
+
+// Middleware wraps handler with additional logic. Registered via Use.
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+type namedMiddleware struct {
+	name string
+	mw   Middleware
+}
+
+// Route registered route information.
+type Route struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares"`
 }
 
 type Opts struct {
@@ -38,6 +64,13 @@ type Opts struct {
 
 	// Cors optional, can be nil, if nil then default will be set.
 	Cors *Cors
+
+	// Admin optional, if not nil then admin server will be run on separate port along with this server.
+	Admin *AdminOpts
+
+	// Tracer optional, trace context of incoming traceparent header is continued by server span of each request.
+	// Default is trace.Default().
+	Tracer *_trace.Tracer
 }
 
 // Cors corst options
@@ -67,7 +100,7 @@ func New(opts *Opts) *Server {
 		})
 	}
 	logger := log.New(os.Stderr, "", 0)
-	return &Server{
+	s := &Server{
 		handlers:     h,
 		port:         opts.Port,
 		idleTimeout:  opts.IdleTimeout,
@@ -75,7 +108,12 @@ func New(opts *Opts) *Server {
 		logger:       logger,
 		cors:         cors,
 		errChan:      make(chan error),
+		tracer:       opts.Tracer,
+	}
+	if opts.Admin != nil {
+		s.admin = s.newAdmin(opts.Admin)
 	}
+	return s
 }
 
 // Run the server. Blocking. Execute it inside goroutine.
@@ -88,6 +126,67 @@ func (s *Server) ListenError() <-chan error {
 	return s.errChan
 }
 
+// Handler return handler of registered routes with cors, e.g. to be served by other http.Server.
+func (s *Server) Handler() http.Handler {
+	return s.cors.Handler(s.handlers)
+}
+
+// Use register middlewares for routes registered afterward. Executed in the order they are passed,
+// after request id, tracing, panic recovery and logging.
+func (s *Server) Use(middlewares ...Middleware) {
+	for _, mw := range middlewares {
+		s.middlewares = append(s.middlewares, namedMiddleware{name: funcName(mw), mw: mw})
+	}
+}
+
+// Routes return all registered routes in registration order.
+func (s *Server) Routes() []Route {
+	s.routesMu.RLock()
+	defer s.routesMu.RUnlock()
+	routes := make([]Route, len(s.routes))
+	copy(routes, s.routes)
+	return routes
+}
+
+func (s *Server) handle(method string, path string, handler http.HandlerFunc) {
+	names := []string{"requestID", "trace", "recoverPanic", "log"}
+	next := handler
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		next = s.middlewares[i].mw(next)
+	}
+	for _, m := range s.middlewares {
+		names = append(names, m.name)
+	}
+	s.handlers.Handle(method, path, f(s.trace(method, path, s.recoverPanic(s.log(next)))))
+
+	s.routesMu.Lock()
+	s.routes = append(s.routes, Route{
+		Method:      method,
+		Path:        path,
+		Handler:     funcName(handler),
+		Middlewares: names,
+	})
+	s.routesMu.Unlock()
+}
+
+func funcName(fn interface{}) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// rawQueryKey context key of request query before path params are added into it.
+type rawQueryKey struct{}
+
+// RawQuery return query of request as sent by client, r.URL.Query() has path params added into it.
+func RawQuery(r *http.Request) string {
+	if rawQuery, ok := r.Context().Value(rawQueryKey{}).(string); ok {
+		return rawQuery
+	}
+	return r.URL.RawQuery
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -112,6 +211,7 @@ func f(next http.HandlerFunc) _router.Handle {
 			r.Header.Set("Request-Id", r.Header.Get("X-Request-Id"))
 		}
 		if len(ps) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), rawQueryKey{}, r.URL.RawQuery))
 			urlValues := r.URL.Query()
 			for i := range ps {
 				urlValues.Add(ps[i].Key, ps[i].Value)
@@ -145,6 +245,36 @@ func (s *Server) log(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// trace continue trace of incoming request with server span, available to handler through request context.
+func (s *Server) trace(method string, path string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tracer := s.tracer
+		if tracer == nil {
+			tracer = _trace.Default()
+		}
+		ctx := _trace.ContextWithRemote(r.Context(), _trace.Extract(r.Header))
+		ctx, span := tracer.Start(ctx, method+" "+path, _trace.SpanKindServer)
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", path)
+		span.SetAttribute("http.target", r.URL.Path)
+		span.SetAttribute("http.host", r.Host)
+		span.SetAttribute("http.user_agent", r.UserAgent())
+		span.SetAttribute("http.request_id", r.Header.Get("Request-Id"))
+
+		next(w, r.WithContext(ctx))
+
+		statusCode := http.StatusOK
+		if rw, ok := w.(*responseWriter); ok {
+			statusCode = rw.statusCode
+		}
+		span.SetAttribute("http.status_code", statusCode)
+		if statusCode >= http.StatusInternalServerError {
+			span.SetStatus(_trace.StatusError, http.StatusText(statusCode))
+		}
+	}
+}
+
 func (s *Server) recoverPanic(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -192,29 +322,29 @@ func ResponseString(w http.ResponseWriter, r *http.Request, statusCode int, body
 }
 
 func (s *Server) GET(path string, handler http.HandlerFunc) {
-	s.handlers.GET(path, f(s.recoverPanic(s.log(handler))))
+	s.handle(http.MethodGet, path, handler)
 }
 
 func (s *Server) HEAD(path string, handler http.HandlerFunc) {
-	s.handlers.HEAD(path, f(s.recoverPanic(s.log(handler))))
+	s.handle(http.MethodHead, path, handler)
 }
 
 func (s *Server) POST(path string, handler http.HandlerFunc) {
-	s.handlers.POST(path, f(s.recoverPanic(s.log(handler))))
+	s.handle(http.MethodPost, path, handler)
 }
 
 func (s *Server) PUT(path string, handler http.HandlerFunc) {
-	s.handlers.POST(path, f(s.recoverPanic(s.log(handler))))
+	s.handle(http.MethodPut, path, handler)
 }
 
 func (s *Server) DELETE(path string, handler http.HandlerFunc) {
-	s.handlers.DELETE(path, f(s.recoverPanic(s.log(handler))))
+	s.handle(http.MethodDelete, path, handler)
 }
 
 func (s *Server) PATCH(path string, handler http.HandlerFunc) {
-	s.handlers.PATCH(path, f(s.recoverPanic(s.log(handler))))
+	s.handle(http.MethodPatch, path, handler)
 }
 
 func (s *Server) OPTIONS(path string, handler http.HandlerFunc) {
-	s.handlers.OPTIONS(path, f(s.recoverPanic(s.log(handler))))
+	s.handle(http.MethodOptions, path, handler)
 }
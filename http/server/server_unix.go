@@ -10,9 +10,13 @@ import (
 )
 
 func (s *Server) serve() error {
-	return _grace.Serve(&http.Server{
+	servers := []*http.Server{{
 		Addr:        fmt.Sprintf(":%d", s.port),
-		Handler:     s.cors.Handler(s.handlers),
+		Handler:     s.Handler(),
 		IdleTimeout: s.idleTimeout,
-	})
+	}}
+	if s.admin != nil {
+		servers = append(servers, s.admin)
+	}
+	return _grace.Serve(servers...)
 }
@@ -13,10 +13,16 @@ import (
 func (s *Server) serve() error {
 	srv := &http.Server{
 		Addr:        fmt.Sprintf(":%d", s.port),
-		Handler:     s.cors.Handler(s.handlers),
+		Handler:     s.Handler(),
 		IdleTimeout: s.idleTimeout,
 	}
 
+	if s.admin != nil {
+		go func() {
+			s.errChan <- s.admin.ListenAndServe()
+		}()
+	}
+
 	// TODO add support for tls
 	l, err := _reuseport.Listen("tcp", srv.Addr)
 	if err != nil {
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+
+	_signature "github.com/mfathirirhas/godevkit/http/signature"
+)
+
+// VerifySignature middleware rejecting requests without valid HMAC signature with 401, e.g. signed by http/client Opts.Signer.
+// Register it via Use.
+func VerifySignature(v *_signature.Verifier) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			req := r
+			// verify against query sent by client, not the one with path params.
+			if rawQuery, ok := r.Context().Value(rawQueryKey{}).(string); ok {
+				req = r.Clone(r.Context())
+				req.URL.RawQuery = rawQuery
+			}
+			if err := v.Verify(req); err != nil {
+				ResponseString(w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
+			r.Body, r.GetBody = req.Body, req.GetBody
+			next(w, r)
+		}
+	}
+}
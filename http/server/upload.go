@@ -0,0 +1,364 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultUploadMaxMemory = 10 << 20 // 10MB
+	sniffLen               = 512
+)
+
+var (
+	ErrUploadNotMultipart    = errors.New("httpserver: request is not multipart")
+	ErrUploadFileTooLarge    = errors.New("httpserver: uploaded file exceeds max file size")
+	ErrUploadFieldTooLarge   = errors.New("httpserver: uploaded field exceeds max memory")
+	ErrUploadTooLarge        = errors.New("httpserver: upload exceeds max total size")
+	ErrUploadTypeNotAllowed  = errors.New("httpserver: uploaded file type is not allowed")
+	ErrUploadStorageNotFound = errors.New("httpserver: uploaded file not found in storage")
+)
+
+// UploadStorage sink for uploaded files bigger than UploadOpts.MaxMemory.
+type UploadStorage interface {
+	// Save store content of r, return location to open or remove it later.
+	// Must not leave partial content behind if returning error.
+	Save(ctx context.Context, fileName string, r io.Reader) (location string, err error)
+	Open(ctx context.Context, location string) (io.ReadCloser, error)
+	Remove(ctx context.Context, location string) error
+}
+
+// UploadOpts options for ParseUpload.
+type UploadOpts struct {
+	// MaxFileSize maximum size of each file in bytes. Zero means no limit.
+	MaxFileSize int64
+
+	// MaxTotalSize maximum size of all parts, files and fields, in bytes. Zero means no limit.
+	MaxTotalSize int64
+
+	// MaxMemory files up to this size are kept in memory, bigger ones are written to Storage.
+	// Also the maximum size of each non-file field, exceeding it is ErrUploadFieldTooLarge. Default is 10MB.
+	MaxMemory int64
+
+	// AllowedTypes allowed content types of files, sniffed from their content instead of trusting the client.
+	// Supports wildcard, e.g. image/*. Empty means all allowed.
+	AllowedTypes []string
+
+	// Storage optional, default is DirStorage on TempDir.
+	Storage UploadStorage
+
+	// TempDir directory for default Storage. Default is os.TempDir().
+	TempDir string
+
+	// Keep if false then stored files are removed once request is done, i.e. the handler returned or the request canceled.
+	// Set to true if Storage is persistent and files are meant to stay.
+	Keep bool
+}
+
+// Upload parsed multipart request.
+type Upload struct {
+	Fields url.Values
+	Files  []*UploadedFile
+
+	storage     UploadStorage
+	cleanupOnce sync.Once
+	cleanupErr  error
+}
+
+// UploadedFile file part of multipart request.
+type UploadedFile struct {
+	FieldName string
+	FileName  string
+
+	// ContentType sniffed from file content.
+	ContentType string
+	Size        int64
+
+	// SHA256 hex encoded checksum of file content.
+	SHA256 string
+
+	// Location in Storage, empty if file is kept in memory.
+	Location string
+
+	data    []byte
+	storage UploadStorage
+}
+
+// Open return content of uploaded file.
+func (f *UploadedFile) Open() (io.ReadCloser, error) {
+	if f.Location == "" {
+		return ioutil.NopCloser(bytes.NewReader(f.data)), nil
+	}
+	return f.storage.Open(context.Background(), f.Location)
+}
+
+// Cleanup remove stored files. Safe to call multiple times.
+func (u *Upload) Cleanup() error {
+	u.cleanupOnce.Do(func() {
+		for _, f := range u.Files {
+			if f.Location == "" {
+				continue
+			}
+			if err := u.storage.Remove(context.Background(), f.Location); err != nil && u.cleanupErr == nil {
+				u.cleanupErr = err
+			}
+		}
+	})
+	return u.cleanupErr
+}
+
+// ParseUpload read multipart request part by part without buffering whole body in memory.
+// Files bigger than MaxMemory are streamed into Storage, checksum and content type computed on the fly.
+// If any limit exceeded or request canceled in the middle, stored files are removed and error returned.
+func ParseUpload(r *http.Request, opts *UploadOpts) (*Upload, error) {
+	if opts == nil {
+		opts = &UploadOpts{}
+	}
+	maxMemory := opts.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultUploadMaxMemory
+	}
+	storage := opts.Storage
+	if storage == nil {
+		storage = &DirStorage{Dir: opts.TempDir}
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, ErrUploadNotMultipart
+	}
+
+	ctx := r.Context()
+	u := &Upload{
+		Fields:  make(url.Values),
+		storage: storage,
+	}
+	total := newUploadLimiter(opts.MaxTotalSize, ErrUploadTooLarge)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			u.Cleanup()
+			return nil, err
+		}
+		if err = ctx.Err(); err != nil {
+			part.Close()
+			u.Cleanup()
+			return nil, err
+		}
+
+		if part.FileName() == "" {
+			err = u.readField(part, total, maxMemory)
+		} else {
+			err = u.readFile(ctx, part, total, opts, maxMemory)
+		}
+		part.Close()
+		if err != nil {
+			u.Cleanup()
+			return nil, err
+		}
+	}
+
+	if !opts.Keep {
+		go func() {
+			<-ctx.Done()
+			u.Cleanup()
+		}()
+	}
+	return u, nil
+}
+
+func (u *Upload) readField(part *multipart.Part, total *uploadLimiter, maxMemory int64) error {
+	field := newUploadLimiter(maxMemory, ErrUploadFieldTooLarge)
+	b, err := ioutil.ReadAll(field.reader(total.reader(part)))
+	if err != nil {
+		return err
+	}
+	u.Fields.Add(part.FormName(), string(b))
+	return nil
+}
+
+func (u *Upload) readFile(ctx context.Context, part *multipart.Part, total *uploadLimiter, opts *UploadOpts, maxMemory int64) error {
+	file := newUploadLimiter(opts.MaxFileSize, ErrUploadFileTooLarge)
+	hash := sha256.New()
+	src := io.TeeReader(file.reader(total.reader(&ctxReader{ctx: ctx, r: part})), hash)
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !typeAllowed(contentType, opts.AllowedTypes) {
+		return ErrUploadTypeNotAllowed
+	}
+
+	// keep in memory up to maxMemory, spill the rest into storage.
+	buf := bytes.NewBuffer(head)
+	if _, err = io.CopyN(buf, src, maxMemory+1-int64(n)); err != nil && err != io.EOF {
+		return err
+	}
+	f := &UploadedFile{
+		FieldName:   part.FormName(),
+		FileName:    filepath.Base(part.FileName()),
+		ContentType: contentType,
+		storage:     u.storage,
+	}
+	if int64(buf.Len()) <= maxMemory {
+		f.data = buf.Bytes()
+		f.Size = int64(buf.Len())
+	} else {
+		counter := &countReader{r: io.MultiReader(buf, src)}
+		if f.Location, err = u.storage.Save(ctx, f.FileName, counter); err != nil {
+			return err
+		}
+		f.Size = counter.n
+	}
+	f.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	u.Files = append(u.Files, f)
+	return nil
+}
+
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == mt || a == "*/*" || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadLimiter remaining bytes shared across readers.
+type uploadLimiter struct {
+	enabled   bool
+	remaining int64
+	err       error
+}
+
+// newUploadLimiter zero or negative max means no limit.
+func newUploadLimiter(max int64, err error) *uploadLimiter {
+	return &uploadLimiter{enabled: max > 0, remaining: max, err: err}
+}
+
+func (l *uploadLimiter) reader(r io.Reader) io.Reader {
+	if !l.enabled {
+		return r
+	}
+	return &limitReader{r: r, l: l}
+}
+
+type limitReader struct {
+	r io.Reader
+	l *uploadLimiter
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	if lr.l.remaining < 0 {
+		return 0, lr.l.err
+	}
+	// read one more byte than remaining to detect exceeding limit.
+	if int64(len(p)) > lr.l.remaining+1 {
+		p = p[:lr.l.remaining+1]
+	}
+	n, err := lr.r.Read(p)
+	lr.l.remaining -= int64(n)
+	if lr.l.remaining < 0 {
+		return 0, lr.l.err
+	}
+	return n, err
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// DirStorage UploadStorage writing files into local directory.
+type DirStorage struct {
+	// Dir default is os.TempDir().
+	Dir string
+}
+
+func (d *DirStorage) dir() string {
+	if d.Dir == "" {
+		return os.TempDir()
+	}
+	return d.Dir
+}
+
+func (d *DirStorage) Save(ctx context.Context, fileName string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(d.dir(), 0744); err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile(d.dir(), "upload-*"+filepath.Ext(fileName))
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(f, &ctxReader{ctx: ctx, r: r}); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (d *DirStorage) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	f, err := os.Open(location)
+	if os.IsNotExist(err) {
+		return nil, ErrUploadStorageNotFound
+	}
+	return f, err
+}
+
+func (d *DirStorage) Remove(ctx context.Context, location string) error {
+	if err := os.Remove(location); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
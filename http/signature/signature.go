@@ -0,0 +1,272 @@
+package signature
+
+/*
+	HMAC-SHA256 request signing shared by http/client and http/server.
+
+	Canonical string signed by both sides, joined by new line:
+		METHOD
+		escaped path
+		query sorted by key then value
+		hex sha256 of body
+		unix timestamp in seconds
+		nonce
+		key id
+*/
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderKeyID     = "X-Signature-Key-Id"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderNonce     = "X-Signature-Nonce"
+	HeaderSignature = "X-Signature"
+
+	defaultMaxSkew     = 5 * time.Minute
+	defaultMaxBodySize = 10 << 20 // 10MB
+	nonceKeyPrefix     = "signature:nonce:"
+)
+
+var (
+	ErrNoKey             = errors.New("signature: no signing key")
+	ErrMissingSignature  = errors.New("signature: missing signature headers")
+	ErrUnknownKey        = errors.New("signature: unknown key id")
+	ErrInvalidTimestamp  = errors.New("signature: invalid timestamp")
+	ErrClockSkew         = errors.New("signature: timestamp is outside allowed clock skew")
+	ErrSignatureMismatch = errors.New("signature: signature mismatch")
+	ErrReplayed          = errors.New("signature: nonce has been used")
+	ErrBodyTooLarge      = errors.New("signature: body too large")
+)
+
+// Key HMAC secret identified by ID, ID is sent along with the signature.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// KeyRing set of active keys, safe to be changed while in use.
+// Signer signs with the primary key, the first one. Verifier accepts any of them.
+// To rotate keys: add new key to verifiers, make it primary on signers, then remove the old one.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys []Key
+}
+
+// NewKeyRing create key ring, the first key is the primary.
+func NewKeyRing(keys ...Key) *KeyRing {
+	kr := &KeyRing{}
+	kr.Set(keys...)
+	return kr
+}
+
+// Set replace all keys, the first key is the primary.
+func (kr *KeyRing) Set(keys ...Key) {
+	ks := make([]Key, len(keys))
+	copy(ks, keys)
+	kr.mu.Lock()
+	kr.keys = ks
+	kr.mu.Unlock()
+}
+
+// Primary return key used for signing.
+func (kr *KeyRing) Primary() (Key, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if len(kr.keys) == 0 {
+		return Key{}, false
+	}
+	return kr.keys[0], true
+}
+
+// Get return key by its id.
+func (kr *KeyRing) Get(id string) (Key, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	for _, k := range kr.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// NonceStore remember used nonces to reject replayed requests, e.g. *redis.Redis or *local.Cache.
+type NonceStore interface {
+	// SetNXTTL set key only if it does not exist, return true if it's set.
+	SetNXTTL(key string, value interface{}, ttl time.Duration) (bool, error)
+}
+
+// Signer sign outgoing requests.
+type Signer struct {
+	Keys *KeyRing
+}
+
+// Sign set signature headers on req. Body is read and replaced, so it stays readable.
+func (s *Signer) Sign(req *http.Request) error {
+	key, ok := s.Keys.Primary()
+	if !ok {
+		return ErrNoKey
+	}
+	body, err := readBody(req, 0)
+	if err != nil {
+		return err
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(HeaderKeyID, key.ID)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, sign(key, canonical(req.Method, req.URL, body, ts, nonce, key.ID)))
+	return nil
+}
+
+// Verifier verify signature of incoming requests.
+type Verifier struct {
+	Keys *KeyRing
+
+	// MaxSkew maximum difference between request timestamp and local clock. Default is 5 minutes.
+	MaxSkew time.Duration
+
+	// Nonces optional, if set then replayed requests are rejected. Nonces are kept for twice of MaxSkew.
+	Nonces NonceStore
+
+	// MaxBodySize maximum body size to be read for verification. Default is 10MB.
+	MaxBodySize int64
+}
+
+// Verify check signature of req. Body is read and replaced, so it stays readable by handler.
+func (v *Verifier) Verify(req *http.Request) error {
+	keyID, ts, nonce, sig := req.Header.Get(HeaderKeyID), req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderNonce), req.Header.Get(HeaderSignature)
+	if keyID == "" || ts == "" || nonce == "" || sig == "" {
+		return ErrMissingSignature
+	}
+	key, ok := v.Keys.Get(keyID)
+	if !ok {
+		return ErrUnknownKey
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = defaultMaxSkew
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return ErrClockSkew
+	}
+	maxBodySize := v.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+	body, err := readBody(req, maxBodySize)
+	if err != nil {
+		return err
+	}
+	expected := sign(key, canonical(req.Method, req.URL, body, ts, nonce, keyID))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrSignatureMismatch
+	}
+	if v.Nonces != nil {
+		ok, err := v.Nonces.SetNXTTL(nonceKeyPrefix+keyID+":"+nonce, ts, 2*maxSkew)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrReplayed
+		}
+	}
+	return nil
+}
+
+func canonical(method string, u *url.URL, body []byte, ts string, nonce string, keyID string) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		u.EscapedPath(),
+		canonicalQuery(u.Query()),
+		hex.EncodeToString(sum[:]),
+		ts,
+		nonce,
+		keyID,
+	}, "\n")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(k))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(v))
+		}
+	}
+	return b.String()
+}
+
+func sign(key Key, s string) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// readBody read whole body and replace it with a replayable one. limit zero means no limit.
+func readBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	var r io.Reader = req.Body
+	if limit > 0 {
+		r = io.LimitReader(req.Body, limit+1)
+	}
+	body, err := ioutil.ReadAll(r)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(body)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
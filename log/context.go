@@ -0,0 +1,113 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	_trace "github.com/mfathirirhas/godevkit/trace"
+	_logrus "github.com/sirupsen/logrus"
+)
+
+func init() {
+	logger.AddHook(traceHook{})
+}
+
+// traceHook add trace and span ids of span in entry context, e.g. logger.WithContext(ctx) of logger created by New.
+type traceHook struct{}
+
+func (traceHook) Levels() []_logrus.Level {
+	return _logrus.AllLevels
+}
+
+func (traceHook) Fire(entry *_logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if sc := _trace.SpanContextFromContext(entry.Context); sc.IsValid() {
+		entry.Data["trace_id"] = sc.TraceID.String()
+		entry.Data["span_id"] = sc.SpanID.String()
+	}
+	return nil
+}
+
+// Entry package logger bound to context, its records include trace and span ids of span in the context.
+type Entry struct {
+	ctx    context.Context
+	fields _logrus.Fields
+}
+
+// WithContext return package logger bound to ctx, e.g. request context of http or grpc server.
+func WithContext(ctx context.Context) *Entry {
+	return &Entry{ctx: ctx}
+}
+
+// WithFields return entry whose records include fields as well.
+func (e *Entry) WithFields(fields map[string]interface{}) *Entry {
+	merged := make(_logrus.Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{ctx: e.ctx, fields: merged}
+}
+
+func (e *Entry) entry() *_logrus.Entry {
+	return logger.WithContext(e.ctx).WithFields(e.fields)
+}
+
+func (e *Entry) Trace(msg ...interface{}) {
+	e.entry().Trace(msg...)
+}
+
+func (e *Entry) Debug(msg ...interface{}) {
+	e.entry().Debug(msg...)
+}
+
+// Info prints to stdout, fields are appended as key=value.
+func (e *Entry) Info(msg ...interface{}) {
+	var fields strings.Builder
+	keys := make([]string, 0, len(e.fields))
+	for k := range e.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&fields, " %s=%v", k, e.fields[k])
+	}
+	if sc := _trace.SpanContextFromContext(e.ctx); sc.IsValid() {
+		fmt.Fprintf(&fields, " trace_id=%s span_id=%s", sc.TraceID, sc.SpanID)
+	}
+	if pc, file, line, ok := runtime.Caller(1); ok {
+		source := formatStdout(file, runtime.FuncForPC(pc).Name(), line)
+		if timeFormat == "" {
+			timeFormat = time.RFC3339
+		}
+		if isRuntimeCaller {
+			fmt.Printf("%s [INFO] %s%s src=%s\n", time.Now().Format(timeFormat), msg, fields.String(), source)
+		} else {
+			fmt.Printf("%s [INFO] %s%s\n", time.Now().Format(timeFormat), msg, fields.String())
+		}
+	}
+}
+
+func (e *Entry) Warn(msg ...interface{}) {
+	e.entry().Warn(msg...)
+}
+
+func (e *Entry) Error(err error, msg ...interface{}) {
+	e.entry().WithFields(_logrus.Fields{"err": err}).Error(msg...)
+}
+
+func (e *Entry) Fatal(err error, msg ...interface{}) {
+	e.entry().WithFields(_logrus.Fields{"err": err}).Fatal(msg...)
+}
+
+func (e *Entry) Panic(err error, msg ...interface{}) {
+	e.entry().WithFields(_logrus.Fields{"err": err}).Panic(msg...)
+}
@@ -119,8 +119,10 @@ type LoggerOpts struct {
 
 // New create logger object with specific location output using logrus as logger.
 // If you want to specify additional logger with its own location.
+// Records logged with logger.WithContext(ctx) include trace and span ids of span in ctx.
 func New(opts *LoggerOpts) (*_logrus.Logger, error) {
 	logger := _logrus.New()
+	logger.AddHook(traceHook{})
 
 	logger.SetLevel(_logrus.InfoLevel)
 	if opts.IsDebug {
@@ -150,6 +152,21 @@ func New(opts *LoggerOpts) (*_logrus.Logger, error) {
 	return logger, nil
 }
 
+// SetLevel change level of package logger at runtime, e.g. "debug", "info", "warn".
+func SetLevel(level string) error {
+	lvl, err := _logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(lvl)
+	return nil
+}
+
+// GetLevel return current level of package logger.
+func GetLevel() string {
+	return logger.GetLevel().String()
+}
+
 func formatStdout(fileName, funcName string, line int) string {
 	paths := strings.Split(fileName, "/")
 	return fmt.Sprintf("%s:%s:%d", fmt.Sprintf("%s/%s", paths[len(paths)-2], paths[len(paths)-1]), funcName, line)
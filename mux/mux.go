@@ -0,0 +1,285 @@
+package mux
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_certs "github.com/mfathirirhas/godevkit/certs"
+	_grpcserver "github.com/mfathirirhas/godevkit/grpc/server"
+	_httpserver "github.com/mfathirirhas/godevkit/http/server"
+	_reuseport "github.com/valyala/fasthttp/reuseport"
+	_http2 "golang.org/x/net/http2"
+	_h2c "golang.org/x/net/http2/h2c"
+)
+
+const (
+	defaultShutdownTimeout = 10 * time.Second
+	drainPollInterval      = 50 * time.Millisecond
+)
+
+var (
+	ErrNoServer = errors.New("mux: neither HTTP nor GRPC server is set")
+)
+
+// Server serve HTTP and gRPC on single port. HTTP/2 requests with application/grpc content type are served by GRPC,
+// the rest by HTTP. Without TLS, HTTP/2 is served in cleartext (h2c), which is what gRPC clients use with insecure credentials.
+type Server struct {
+	http            *_httpserver.Server
+	grpc            *_grpcserver.Server
+	port            uint16
+	listener        net.Listener
+	shutdownTimeout time.Duration
+	errChan         chan error
+	certs           *_certs.Reloader
+	srv             *http.Server
+	inflight        int64
+
+	mu  sync.Mutex
+	lis net.Listener
+}
+
+type Opts struct {
+	// Port listened on all IPv4 interfaces with SO_REUSEPORT. Ignored if Listener is set.
+	Port uint16
+
+	// Listener optional, custom listener.
+	Listener net.Listener
+
+	// HTTP optional, serve non-gRPC requests, its Port, Admin and Run are not used. If nil then they are responded 404.
+	HTTP *_httpserver.Server
+
+	// GRPC optional, serve gRPC calls, its Port, Listener, TLS, Keepalive and MaxConcurrentStreams options
+	// and Run are not used. If nil then calls are served by HTTP.
+	GRPC *_grpcserver.Server
+
+	// TLS optional, if set then connections of both servers are secured with TLS, and with mTLS if ClientCAFiles is set.
+	TLS *TLSOpts
+
+	// IdleTimeout keep-alive timeout while waiting for the next request coming. If empty then no timeout.
+	IdleTimeout time.Duration
+
+	// MaxConcurrentStreams optional, limit of concurrent streams, i.e. requests and calls, of each HTTP/2 connection.
+	// Default is 250.
+	MaxConcurrentStreams uint32
+
+	// ShutdownTimeout how long Run waits for in-flight requests and calls once its ctx is done, before they are cancelled.
+	// Default is 10 seconds.
+	ShutdownTimeout time.Duration
+}
+
+// TLSOpts TLS options shared by both servers. Files are PEM and reloaded once they change on disk,
+// see certs.WriteSelfSigned for local testing.
+type TLSOpts struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFiles optional, if set then clients must present certificate signed by one of them.
+	ClientCAFiles []string
+	// ClientCertOptional if true then clients without certificate are accepted, presented ones are still verified.
+	ClientCertOptional bool
+
+	// MinVersion minimum TLS version, e.g. tls.VersionTLS13. Default is TLS 1.2.
+	MinVersion uint16
+
+	// ReloadInterval how often files are checked for changes. Default is 1 minute, negative disables reload.
+	ReloadInterval time.Duration
+}
+
+func New(opts *Opts) (*Server, error) {
+	if opts.HTTP == nil && opts.GRPC == nil {
+		return nil, ErrNoServer
+	}
+	s := &Server{
+		http:            opts.HTTP,
+		grpc:            opts.GRPC,
+		port:            opts.Port,
+		listener:        opts.Listener,
+		shutdownTimeout: opts.ShutdownTimeout,
+		errChan:         make(chan error, 1),
+	}
+	if s.shutdownTimeout <= 0 {
+		s.shutdownTimeout = defaultShutdownTimeout
+	}
+	s.srv = &http.Server{
+		Handler:     s,
+		IdleTimeout: opts.IdleTimeout,
+	}
+	h2 := &_http2.Server{
+		MaxConcurrentStreams: opts.MaxConcurrentStreams,
+		IdleTimeout:          opts.IdleTimeout,
+	}
+	if opts.TLS != nil {
+		config, err := s.tlsConfig(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		s.srv.TLSConfig = config
+	} else {
+		s.srv.Handler = _h2c.NewHandler(s, h2)
+	}
+	// h2c connections are served by h2 as well, so they are shut down gracefully along with s.srv.
+	if err := _http2.ConfigureServer(s.srv, h2); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) tlsConfig(opts *TLSOpts) (*tls.Config, error) {
+	reloader, err := _certs.NewReloader(&_certs.Opts{
+		CertFile:       opts.CertFile,
+		KeyFile:        opts.KeyFile,
+		CAFiles:        opts.ClientCAFiles,
+		ReloadInterval: opts.ReloadInterval,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.certs = reloader
+	clientAuth := tls.RequireAndVerifyClientCert
+	if opts.ClientCertOptional {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	config := reloader.ServerConfig(clientAuth)
+	if opts.MinVersion > 0 {
+		config.MinVersion = opts.MinVersion
+	}
+	config.NextProtos = []string{_http2.NextProtoTLS, "http/1.1"}
+	return config, nil
+}
+
+// ServeHTTP dispatch request to GRPC or HTTP.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.inflight, 1)
+	defer atomic.AddInt64(&s.inflight, -1)
+	switch {
+	case s.grpc != nil && r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc"):
+		s.grpc.ServeHTTP(w, r)
+	case s.http != nil:
+		s.http.Handler().ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Run listen and serve until ctx is done or Shutdown is called. Blocking, execute it inside goroutine.
+// Once ctx is done, in-flight requests and calls are given ShutdownTimeout to finish. Return nil if server is stopped,
+// otherwise the listen or serve error, which is sent to ListenError as well.
+func (s *Server) Run(ctx context.Context) error {
+	lis := s.listener
+	if lis == nil {
+		var err error
+		lis, err = _reuseport.Listen("tcp4", fmt.Sprintf(":%d", s.port))
+		if err != nil {
+			s.fail(err)
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.lis = lis
+	s.mu.Unlock()
+
+	// once ctx is done, Serve returns right away, Run returns once in-flight requests are drained.
+	served := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+			defer cancel()
+			s.Shutdown(shutdownCtx)
+		case <-served:
+		}
+	}()
+
+	var err error
+	if s.certs != nil {
+		err = s.srv.ServeTLS(lis, "", "")
+	} else {
+		err = s.srv.Serve(lis)
+	}
+	close(served)
+	<-stopped
+	if err != nil && err != http.ErrServerClosed {
+		s.fail(err)
+		return err
+	}
+	return nil
+}
+
+// fail send err to ListenError without blocking if nobody listens.
+func (s *Server) fail(err error) {
+	select {
+	case s.errChan <- err:
+	default:
+	}
+}
+
+// Addr return address listened on, nil if server is not running.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Shutdown report not serving through GRPC health service and end its watches, stop accepting connections
+// and wait for in-flight requests and calls to finish. Once ctx is done, they are cancelled and connections are closed,
+// ctx error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	defer func() {
+		if s.certs != nil {
+			s.certs.Close()
+		}
+	}()
+	if s.grpc != nil {
+		s.grpc.Drain()
+	}
+	err := s.srv.Shutdown(ctx)
+	if err == nil {
+		err = s.drain(ctx)
+	}
+	if s.grpc != nil {
+		s.grpc.Close()
+	}
+	if err != nil {
+		s.srv.Close()
+	}
+	return err
+}
+
+// drain wait for in-flight requests, including the ones of h2c connections which http.Server doesn't track.
+func (s *Server) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&s.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Stop stop server gracefully, waiting for in-flight requests and calls up to ShutdownTimeout before they are cancelled.
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	s.Shutdown(ctx)
+}
+
+// ListenError return channel receiving listen or serve error of Run.
+func (s *Server) ListenError() <-chan error {
+	return s.errChan
+}
@@ -0,0 +1,377 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderTraceparent and HeaderTracestate W3C trace context headers, also used as grpc metadata keys.
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+
+	traceparentVersion = "00"
+	flagSampled        = 0x01
+	maxTracestateLen   = 512
+)
+
+var (
+	ErrInvalidTraceparent = errors.New("trace: invalid traceparent")
+)
+
+// TraceID identifier of a trace.
+type TraceID [16]byte
+
+// IsValid false if all bytes are zero.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// SpanID identifier of a span.
+type SpanID [8]byte
+
+// IsValid false if all bytes are zero.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SpanContext identity of span propagated across services.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	// Remote true if it's extracted from incoming request.
+	Remote bool
+}
+
+// IsValid true if both trace id and span id are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent return traceparent header value, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func (sc SpanContext) Traceparent() string {
+	var flags byte
+	if sc.Sampled {
+		flags = flagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// Parse parse traceparent and tracestate header values.
+func Parse(traceparent, tracestate string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	// version ff is invalid, version 00 has exactly 4 parts, future versions may have more.
+	if parts[0] == "ff" || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || !isLowerHex(parts[1]) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || !isLowerHex(parts[2]) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&flagSampled != 0
+	sc.Remote = true
+	if ts := strings.TrimSpace(tracestate); len(ts) <= maxTracestateLen {
+		sc.TraceState = ts
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	return strings.ToLower(s) == s
+}
+
+// Carrier headers to extract span context from and inject it into, e.g. http.Header.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// Extract return span context of traceparent and tracestate in carrier, invalid if there is none.
+func Extract(carrier Carrier) SpanContext {
+	sc, err := Parse(carrier.Get(HeaderTraceparent), carrier.Get(HeaderTracestate))
+	if err != nil {
+		return SpanContext{}
+	}
+	return sc
+}
+
+// Inject set traceparent and tracestate of span in ctx into carrier. Nothing is set if ctx has no span.
+func Inject(ctx context.Context, carrier Carrier) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	carrier.Set(HeaderTraceparent, sc.Traceparent())
+	if sc.TraceState != "" {
+		carrier.Set(HeaderTracestate, sc.TraceState)
+	}
+}
+
+// SpanKind role of span, values follow OTLP.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+func (k SpanKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// StatusCode status of span, values follow OTLP.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+func (c StatusCode) String() string {
+	switch c {
+	case StatusOK:
+		return "ok"
+	case StatusError:
+		return "error"
+	}
+	return "unset"
+}
+
+func (c StatusCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Span operation of a trace. Methods are safe on nil Span.
+type Span struct {
+	tracer *Tracer
+
+	mu            sync.Mutex
+	name          string
+	kind          SpanKind
+	sc            SpanContext
+	parent        SpanID
+	start         time.Time
+	end           time.Time
+	attributes    map[string]interface{}
+	status        StatusCode
+	statusMessage string
+	ended         bool
+}
+
+// SpanData finished span passed to Exporter.
+type SpanData struct {
+	Service       string                 `json:"service"`
+	Name          string                 `json:"name"`
+	Kind          SpanKind               `json:"kind"`
+	TraceID       TraceID                `json:"trace_id"`
+	SpanID        SpanID                 `json:"span_id"`
+	ParentSpanID  SpanID                 `json:"parent_span_id"`
+	TraceState    string                 `json:"trace_state,omitempty"`
+	Start         time.Time              `json:"start"`
+	End           time.Time              `json:"end"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        StatusCode             `json:"status"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+}
+
+// SpanContext return identity of span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName replace name of span, e.g. once route is known. No-op once span is ended.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended {
+		s.name = name
+	}
+	s.mu.Unlock()
+}
+
+// SetAttribute set attribute of span, value should be string, bool, integer or float. No-op once span is ended.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// SetStatus set status of span. Once it's error, it can only be replaced by another error. No-op once span is ended.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended && (s.status != StatusError || code == StatusError) {
+		s.status, s.statusMessage = code, message
+	}
+	s.mu.Unlock()
+}
+
+// SetError set error status of span along with error attribute, nothing if err is nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetAttribute("error.message", err.Error())
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finish span and queue it for export if it's sampled. Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	// exported copy, so the tracer goroutine encoding it doesn't race with the span.
+	var attributes map[string]interface{}
+	if len(s.attributes) > 0 {
+		attributes = make(map[string]interface{}, len(s.attributes))
+		for k, v := range s.attributes {
+			attributes[k] = v
+		}
+	}
+	data := SpanData{
+		Name:          s.name,
+		Kind:          s.kind,
+		TraceID:       s.sc.TraceID,
+		SpanID:        s.sc.SpanID,
+		ParentSpanID:  s.parent,
+		TraceState:    s.sc.TraceState,
+		Start:         s.start,
+		End:           s.end,
+		Attributes:    attributes,
+		Status:        s.status,
+		StatusMessage: s.statusMessage,
+	}
+	s.mu.Unlock()
+	if s.sc.Sampled {
+		s.tracer.export(data)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan return ctx carrying span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// ContextWithRemote return ctx carrying span context extracted from incoming request, as parent of spans started with it.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return ContextWithSpan(ctx, &Span{sc: sc, ended: true})
+}
+
+// FromContext return span in ctx, nil if none.
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext return span context of span in ctx, invalid if none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	return FromContext(ctx).SpanContext()
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// sampled decide whether new trace is sampled from the lower bits of its id.
+func sampled(id TraceID, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:])>>1 < uint64(ratio*(1<<63))
+}
@@ -0,0 +1,252 @@
+package trace
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueSize     = 2048
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5 * time.Second
+)
+
+// Opts options of Tracer.
+type Opts struct {
+	// ServiceName name of this service, exported along with spans.
+	ServiceName string
+
+	// Exporter optional, if nil then spans are only propagated, never exported.
+	Exporter Exporter
+
+	// SampleRatio ratio of new traces sampled, between 0 and 1. Spans with parent follow sampling decision of the parent.
+	// Default is 1, i.e. every trace. Negative means none.
+	SampleRatio float64
+
+	// QueueSize maximum spans waiting for export, spans ended while queue is full are dropped. Default is 2048.
+	QueueSize int
+	// BatchSize maximum spans per export. Default is 512.
+	BatchSize int
+	// FlushInterval how often queued spans are exported. Default is 5 seconds.
+	FlushInterval time.Duration
+}
+
+// Tracer start spans and export them in batches in background.
+type Tracer struct {
+	service       string
+	exporter      Exporter
+	ratio         float64
+	batchSize     int
+	flushInterval time.Duration
+	logger        *log.Logger
+
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultTracer *Tracer
+)
+
+// New create tracer. Call Shutdown on exit to export remaining spans.
+func New(opts *Opts) *Tracer {
+	t := &Tracer{
+		service:       opts.ServiceName,
+		exporter:      opts.Exporter,
+		ratio:         opts.SampleRatio,
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		logger:        log.New(os.Stderr, "", 0),
+	}
+	if t.ratio == 0 {
+		t.ratio = 1
+	}
+	if t.batchSize <= 0 {
+		t.batchSize = defaultBatchSize
+	}
+	if t.flushInterval <= 0 {
+		t.flushInterval = defaultFlushInterval
+	}
+	if t.exporter != nil {
+		queueSize := opts.QueueSize
+		if queueSize <= 0 {
+			queueSize = defaultQueueSize
+		}
+		t.queue = make(chan SpanData, queueSize)
+		t.flush = make(chan chan struct{})
+		t.done = make(chan struct{})
+		t.stopped = make(chan struct{})
+		go t.run()
+	}
+	return t
+}
+
+// Default return tracer used by http/server, http/client and grpc packages if none is set in their options.
+// It only propagates trace context unless replaced by SetDefault.
+func Default() *Tracer {
+	defaultMu.RLock()
+	t := defaultTracer
+	defaultMu.RUnlock()
+	if t != nil {
+		return t
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultTracer == nil {
+		defaultTracer = New(&Opts{})
+	}
+	return defaultTracer
+}
+
+// SetDefault replace tracer returned by Default. Nil restores the built-in one.
+func SetDefault(t *Tracer) {
+	defaultMu.Lock()
+	defaultTracer = t
+	defaultMu.Unlock()
+}
+
+// Start start span as child of span in ctx, or as root of new trace if there is none. End must be called on the span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		span.sc = SpanContext{
+			TraceID:    parent.TraceID,
+			SpanID:     newSpanID(),
+			Sampled:    parent.Sampled,
+			TraceState: parent.TraceState,
+		}
+		span.parent = parent.SpanID
+	} else {
+		span.sc = SpanContext{
+			TraceID: newTraceID(),
+			SpanID:  newSpanID(),
+		}
+		span.sc.Sampled = sampled(span.sc.TraceID, t.ratio)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Start start span with Default tracer.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind)
+}
+
+// Flush export queued spans and wait until they are exported or ctx is done.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	select {
+	case t.flush <- done:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown export queued spans and shutdown exporter. Spans ended afterward are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	t.stopOnce.Do(func() {
+		close(t.done)
+	})
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t == nil || t.exporter == nil {
+		return
+	}
+	data.Service = t.service
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	select {
+	case t.queue <- data:
+	default:
+		// drop rather than block the request.
+	}
+}
+
+func (t *Tracer) run() {
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, t.batchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.flushInterval)
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			t.logger.Printf("%s | trace | EXPORT | %d spans | %v\n", time.Now().Format(time.RFC3339), len(batch), err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, t.batchSize)
+	}
+	// drain export all spans queued so far.
+	drain := func() {
+		for {
+			select {
+			case data := <-t.queue:
+				batch = append(batch, data)
+				if len(batch) >= t.batchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-t.flush:
+			drain()
+			close(done)
+		case <-t.done:
+			drain()
+			close(t.stopped)
+			return
+		}
+	}
+}